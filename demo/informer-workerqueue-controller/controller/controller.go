@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sync"
+	"time"
+)
+
+// Run退出时默认等待队列排空的时间，和Pod的terminationGracePeriodSeconds默认值一致
+const DefaultGracePeriod = 30 * time.Second
+
+// Reconcile的结果
+type Result struct {
+	// 按workqueue的限速重新排队
+	Requeue bool
+	// 大于0时在这个时间之后重新排队，优先于Requeue
+	RequeueAfter time.Duration
+}
+
+// 业务逻辑，key为namespace/name。对象已经被删除时同样会被调用，需要自己从缓存中判断
+type Reconciler interface {
+	Reconcile(ctx context.Context, key string) (Result, error)
+}
+
+// 把普通函数适配成Reconciler
+type ReconcilerFunc func(ctx context.Context, key string) (Result, error)
+
+func (f ReconcilerFunc) Reconcile(ctx context.Context, key string) (Result, error) {
+	return f(ctx, key)
+}
+
+// 观察主资源informer中的对象，把变化的key放进workqueue，由多个worker调用Reconciler处理。
+// 通过Watch还可以观察次要资源，次要资源变化时同步对应的主资源
+type Controller struct {
+	name       string
+	reconciler Reconciler
+	synced     []cache.InformerSynced
+	queue      workqueue.RateLimitingInterface
+	// 主资源的缓存，记录Event时从中取出对象
+	stores     MultiStore
+	maxRetries int
+	clock      clock.Clock
+	// 可选，为nil时不记录Event
+	recorder    record.EventRecorder
+	deadLetters deadLetters
+	// 上一次同步出错的key，恢复时同步成功的Event注明出错后恢复
+	failing failingKeys
+	// Run退出时等待队列排空的最长时间
+	gracePeriod time.Duration
+}
+
+// 实例化Controller，informer为主资源。informer由调用方启动，可以和其他controller共享
+func New(name string, informer cache.SharedIndexInformer, reconciler Reconciler) *Controller {
+	return NewWithRetry(name, informer, reconciler, DefaultRetryConfig(), clock.RealClock{})
+}
+
+// 同New，按retry限速和重试，clock用于重试的延迟和死信的时间
+func NewWithRetry(name string, informer cache.SharedIndexInformer, reconciler Reconciler, retry RetryConfig, clock clock.WithTicker) *Controller {
+	c := &Controller{
+		name:        name,
+		reconciler:  reconciler,
+		gracePeriod: DefaultGracePeriod,
+		queue:       newRateLimitingQueue(retry.rateLimiter(), name, clock),
+		maxRetries:  retry.MaxRetries,
+		clock:       clock,
+	}
+	c.AddInformer(informer)
+	return c
+}
+
+// 添加同一种主资源的另一个informer，例如每个命名空间分别创建的informer，
+// 它们的事件合并到同一个workqueue中。需要在Run之前调用
+func (c *Controller) AddInformer(informer cache.SharedIndexInformer) {
+	c.synced = append(c.synced, informer.HasSynced)
+	c.stores = append(c.stores, informer.GetIndexer())
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueue(newObj)
+		},
+		DeleteFunc: c.enqueue,
+	})
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	// 删除时可能收到DeletedFinalStateUnknown，必须使用这个键函数
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// 观察次要资源，对象变化时把mapFunc返回的主资源key放进workqueue，需要在Run之前调用
+func (c *Controller) Watch(informer cache.SharedIndexInformer, mapFunc MapFunc) {
+	c.synced = append(c.synced, informer.HasSynced)
+	handle := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		for _, key := range mapFunc(obj) {
+			c.queue.Add(key)
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: handle,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// 例如ownerReferences变化时，新旧owner都要同步
+			handle(oldObj)
+			handle(newObj)
+		},
+		DeleteFunc: handle,
+	})
+}
+
+// 负责观察和同步，ctx取消时返回：不再接收新的key，worker处理完队列中剩余的key后退出。
+// 超过gracePeriod还没处理完时取消传给Reconcile的ctx，剩下的key不再处理，仍然等待正在处理的key返回。
+// Run返回后Controller不能再次运行
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	return c.RunWithAbort(ctx, nil, workers)
+}
+
+// 同Run，abort关闭后不再排空队列：立即取消传给Reconcile的ctx，只等待正在处理的key返回。
+// 用于失去leader时尽快停止，剩下的key由新的leader处理
+func (c *Controller) RunWithAbort(ctx context.Context, abort <-chan struct{}, workers int) error {
+	defer runtime.HandleCrash()
+
+	klog.Infof("启动 %s controller", c.name)
+
+	// 处理之前，等待所有涉及的缓存被同步
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced...) {
+		c.queue.ShutDown()
+		return fmt.Errorf("同步超时了")
+	}
+
+	// 传给Reconcile的ctx不随ctx取消，排空队列时正在处理的key可以正常完成
+	reconcileCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 创建多个work处理
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// workqueue关闭且取完所有key后返回
+			c.runWorker(reconcileCtx)
+		}()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-ctx.Done():
+		klog.Infof("停止 %s controller，处理队列中剩余的 %d 个key", c.name, c.queue.Len())
+	case <-abort:
+	}
+	// 之后Add的key会被忽略，Get取完剩余的key后worker退出
+	c.queue.ShutDown()
+
+	timer := time.NewTimer(c.gracePeriod)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-abort:
+		klog.Warningf("%s controller 立即停止，放弃剩余的 %d 个key", c.name, c.queue.Len())
+		cancel()
+		<-stopped
+	case <-timer.C:
+		klog.Warningf("%s controller 超过 %v 没有处理完，放弃剩余的 %d 个key", c.name, c.gracePeriod, c.queue.Len())
+		cancel()
+		<-stopped
+	}
+	klog.Infof("停止 %s controller", c.name)
+	return nil
+}
+
+// 所有涉及的informer的缓存是否都已同步
+func (c *Controller) HasSynced() bool {
+	for _, synced := range c.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// 设置记录Event的recorder，需要在Run之前调用
+func (c *Controller) SetEventRecorder(recorder record.EventRecorder) {
+	c.recorder = recorder
+}
+
+// 设置Run退出时排空队列的最长时间，需要在Run之前调用
+func (c *Controller) SetGracePeriod(gracePeriod time.Duration) {
+	c.gracePeriod = gracePeriod
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+	// 超过了退出的宽限期，不再处理
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// 调用业务逻辑
+	start := time.Now()
+	result, err := c.reconciler.Reconcile(ctx, key.(string))
+	observeReconcile(c.name, result, err, time.Since(start))
+
+	// 如果发现错误，处理错误，并重试
+	c.handleResult(key, result, err)
+	return true
+}
+
+// 处理Reconcile的结果
+func (c *Controller) handleResult(key interface{}, result Result, err error) {
+	if err != nil {
+		c.handleErr(err, key)
+		return
+	}
+	c.queue.Forget(key)
+	c.deadLetters.remove(key.(string))
+	// 相同的Event由recorder合并成一条并累加次数，每次同步成功都记录
+	if c.failing.remove(key.(string)) {
+		c.event(key.(string), corev1.EventTypeNormal, ReasonSynced, "%s controller 出错后同步成功", c.name)
+	} else {
+		c.event(key.(string), corev1.EventTypeNormal, ReasonSynced, "%s controller 同步成功", c.name)
+	}
+	switch {
+	case result.RequeueAfter > 0:
+		c.queue.AddAfter(key, result.RequeueAfter)
+	case result.Requeue:
+		c.queue.AddRateLimited(key)
+	}
+}
+
+// 处理错误
+func (c *Controller) handleErr(err error, key interface{}) {
+	// 如果出现问题，按配置的次数重试
+	requeues := c.queue.NumRequeues(key)
+	c.failing.add(key.(string))
+	if requeues < c.maxRetries {
+		klog.Infof("同步 %v 错误: %v", key, err)
+		c.event(key.(string), corev1.EventTypeWarning, ReasonSyncFailed, "同步出错，第%d次重试: %v", requeues+1, err)
+
+		// 重新排队，稍后重新再试
+		c.queue.AddRateLimited(key)
+		return
+	}
+
+	// 不再重试，key放进死信，对象再次变化时重新同步
+	c.queue.Forget(key)
+	runtime.HandleError(fmt.Errorf("放弃同步 %v: %v", key, err))
+	c.deadLetters.add(DeadLetter{Key: key.(string), Error: err.Error(), Retries: requeues, Time: c.clock.Now()})
+	c.event(key.(string), corev1.EventTypeWarning, ReasonDeadLetter, "重试%d次后放弃同步: %v", requeues, err)
+}
+
+// 在主资源对象上记录Event，对象已经被删除时忽略
+func (c *Controller) event(key, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.recorder == nil {
+		return
+	}
+	obj, exists, err := c.stores.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	c.recorder.Eventf(obj.(k8sruntime.Object), eventType, reason, messageFmt, args...)
+}
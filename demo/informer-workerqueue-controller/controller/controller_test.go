@@ -0,0 +1,313 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录每次Reconcile的key，返回预先设置的结果
+type recorder struct {
+	mu      sync.Mutex
+	calls   []string
+	results map[string][]reconcileResult
+}
+
+type reconcileResult struct {
+	result Result
+	err    error
+}
+
+func newRecorder() *recorder {
+	return &recorder{results: map[string][]reconcileResult{}}
+}
+
+func (r *recorder) Reconcile(ctx context.Context, key string) (Result, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, key)
+	var next reconcileResult
+	if results := r.results[key]; len(results) > 0 {
+		next, r.results[key] = results[0], results[1:]
+	}
+	r.mu.Unlock()
+	return next.result, next.err
+}
+
+// 等待key被处理n次，超时则失败
+func (r *recorder) wait(t *testing.T, key string, n int) {
+	t.Helper()
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return r.count(key) >= n, nil
+	})
+	if err != nil {
+		t.Fatalf("%s reconciled %d times, want %d", key, r.count(key), n)
+	}
+}
+
+func (r *recorder) count(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, call := range r.calls {
+		if call == key {
+			n++
+		}
+	}
+	return n
+}
+
+func newPod(name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+}
+
+// 通过fake clientset启动informer和controller，返回clientset
+func start(t *testing.T, r Reconciler) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	c := New("pod", factory.Core().V1().Pods().Informer(), r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	factory.Start(ctx.Done())
+	go c.Run(ctx, 2)
+	return client
+}
+
+func TestReconcileOnEvents(t *testing.T) {
+	r := newRecorder()
+	client := start(t, r)
+	pods := client.CoreV1().Pods("default")
+
+	if _, err := pods.Create(context.TODO(), newPod("a"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/a", 1)
+
+	pod := newPod("a")
+	pod.Labels = map[string]string{"app": "demo"}
+	if _, err := pods.Update(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/a", 2)
+
+	if err := pods.Delete(context.TODO(), "a", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/a", 3)
+}
+
+func TestReconcileRequeue(t *testing.T) {
+	r := newRecorder()
+	r.results["default/retry"] = []reconcileResult{{err: errors.New("boom")}, {err: errors.New("boom")}}
+	r.results["default/after"] = []reconcileResult{{result: Result{RequeueAfter: 50 * time.Millisecond}}}
+	r.results["default/requeue"] = []reconcileResult{{result: Result{Requeue: true}}}
+	client := start(t, r)
+
+	for _, name := range []string{"retry", "after", "requeue"} {
+		if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod(name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 出错两次后第三次成功
+	r.wait(t, "default/retry", 3)
+	r.wait(t, "default/after", 2)
+	r.wait(t, "default/requeue", 2)
+
+	time.Sleep(100 * time.Millisecond)
+	for key, want := range map[string]int{"default/retry": 3, "default/after": 2, "default/requeue": 2} {
+		if n := r.count(key); n != want {
+			t.Errorf("%s reconciled %d times, want %d", key, n, want)
+		}
+	}
+}
+
+func TestGiveUpAfterMaxRetries(t *testing.T) {
+	r := newRecorder()
+	for i := 0; i < 10; i++ {
+		r.results["default/broken"] = append(r.results["default/broken"], reconcileResult{err: errors.New("boom")})
+	}
+	client := start(t, r)
+	if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod("broken"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次加上5次重试
+	maxRetries := DefaultRetryConfig().MaxRetries
+	r.wait(t, "default/broken", maxRetries+1)
+	time.Sleep(500 * time.Millisecond)
+	if n := r.count("default/broken"); n != maxRetries+1 {
+		t.Errorf("reconciled %d times, want %d", n, maxRetries+1)
+	}
+}
+
+// 第一个key阻塞在Reconcile中，其余key排在队列里，返回Run的结果
+type blockingRun struct {
+	c      *Controller
+	client *fake.Clientset
+	r      *recorder
+	cancel context.CancelFunc
+	// 关闭后不再排空队列，见RunWithAbort
+	abort   chan struct{}
+	stopped chan error
+	// 第一个key开始处理、被放行
+	started, release chan struct{}
+	// 第一个key的Reconcile返回时ctx是否已经取消
+	canceled chan bool
+}
+
+func startBlocking(t *testing.T, gracePeriod time.Duration, names ...string) *blockingRun {
+	b := &blockingRun{
+		client:   fake.NewSimpleClientset(),
+		r:        newRecorder(),
+		abort:    make(chan struct{}),
+		stopped:  make(chan error, 1),
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		canceled: make(chan bool, 1),
+	}
+	first := "default/" + names[0]
+	reconciler := ReconcilerFunc(func(ctx context.Context, key string) (Result, error) {
+		if key == first {
+			close(b.started)
+			select {
+			case <-b.release:
+			case <-ctx.Done():
+			}
+			b.canceled <- ctx.Err() != nil
+		}
+		return b.r.Reconcile(ctx, key)
+	})
+
+	factory := informers.NewSharedInformerFactory(b.client, 0)
+	c := New("pod", factory.Core().V1().Pods().Informer(), reconciler)
+	c.SetGracePeriod(gracePeriod)
+	b.c = c
+
+	informerCtx, stopInformer := context.WithCancel(context.Background())
+	t.Cleanup(stopInformer)
+	factory.Start(informerCtx.Done())
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	t.Cleanup(cancel)
+	// 只有一个worker，第一个key阻塞时其余key都在队列中
+	go func() { b.stopped <- c.RunWithAbort(ctx, b.abort, 1) }()
+
+	for i, name := range names {
+		if _, err := b.client.CoreV1().Pods("default").Create(context.TODO(), newPod(name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			<-b.started
+		}
+	}
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.queue.Len() == len(names)-1, nil
+	})
+	if err != nil {
+		t.Fatalf("queue length %d, want %d", c.queue.Len(), len(names)-1)
+	}
+	return b
+}
+
+func TestShutdownDrainsQueue(t *testing.T) {
+	b := startBlocking(t, time.Minute, "a", "b", "c", "d")
+
+	b.cancel()
+	// 排空期间的事件被忽略，不影响正在处理的key
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return b.c.queue.ShuttingDown(), nil
+	}); err != nil {
+		t.Fatal("queue not shutting down")
+	}
+	pod := newPod("a")
+	pod.Labels = map[string]string{"app": "demo"}
+	if _, err := b.client.CoreV1().Pods("default").Update(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-b.stopped:
+		t.Fatal("Run returned before the in-flight key finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(b.release)
+	select {
+	case err := <-b.stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after draining the queue")
+	}
+	if <-b.canceled {
+		t.Error("in-flight reconcile was canceled")
+	}
+	for _, key := range []string{"default/a", "default/b", "default/c", "default/d"} {
+		if n := b.r.count(key); n != 1 {
+			t.Errorf("%s reconciled %d times, want 1", key, n)
+		}
+	}
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	b := startBlocking(t, 100*time.Millisecond, "a", "b", "c")
+
+	start := time.Now()
+	b.cancel()
+	select {
+	case err := <-b.stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the grace period")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Run returned after %v, before the grace period", elapsed)
+	}
+
+	// 超过宽限期时取消正在处理的key的ctx，等它返回，剩余的key不再处理
+	if !<-b.canceled {
+		t.Error("in-flight reconcile was not canceled")
+	}
+	if n := b.r.count("default/a"); n != 1 {
+		t.Errorf("default/a reconciled %d times, want 1", n)
+	}
+	for _, key := range []string{"default/b", "default/c"} {
+		if n := b.r.count(key); n != 0 {
+			t.Errorf("%s reconciled %d times after the grace period", key, n)
+		}
+	}
+}
+
+// 失去leader时不等宽限期，立即取消正在处理的key，剩余的key不再处理
+func TestShutdownAbort(t *testing.T) {
+	b := startBlocking(t, time.Minute, "a", "b", "c")
+
+	b.cancel()
+	close(b.abort)
+	select {
+	case err := <-b.stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after abort")
+	}
+	if !<-b.canceled {
+		t.Error("in-flight reconcile was not canceled")
+	}
+	for _, key := range []string{"default/b", "default/c"} {
+		if n := b.r.count(key); n != 0 {
+			t.Errorf("%s reconciled %d times after abort", key, n)
+		}
+	}
+}
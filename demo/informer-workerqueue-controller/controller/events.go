@@ -0,0 +1,29 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Controller在主资源对象上记录的Event的reason
+const (
+	// 同步成功，Normal。从出错中恢复时消息会注明
+	ReasonSynced = "Synced"
+	// 同步出错，稍后重试，Warning
+	ReasonSyncFailed = "SyncFailed"
+	// 重试次数用完，放弃同步，Warning
+	ReasonDeadLetter = "DeadLetter"
+)
+
+// 创建写入events资源的EventRecorder，component为Event的source。
+// 返回的函数停止broadcaster，退出前调用
+func NewEventRecorder(client kubernetes.Interface, component string) (record.EventRecorder, func()) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartStructuredLogging(4)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component})
+	return recorder, broadcaster.Shutdown
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"testing"
+	"time"
+)
+
+// 从FakeRecorder中取出一个Event，超时则失败
+func nextEvent(t *testing.T, recorder *record.FakeRecorder) string {
+	t.Helper()
+	select {
+	case event := <-recorder.Events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event recorded")
+		return ""
+	}
+}
+
+func TestEvents(t *testing.T) {
+	r := newRecorder()
+	r.results["default/flaky"] = []reconcileResult{{err: errors.New("boom")}}
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	c := New("pod", factory.Core().V1().Pods().Informer(), r)
+	recorder := record.NewFakeRecorder(10)
+	c.SetEventRecorder(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	go c.Run(ctx, 1)
+
+	pods := client.CoreV1().Pods("default")
+	if _, err := pods.Create(context.TODO(), newPod("web"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 同步成功
+	r.wait(t, "default/web", 1)
+	if event := nextEvent(t, recorder); event != "Normal Synced pod controller 同步成功" {
+		t.Errorf("event = %q", event)
+	}
+
+	// 出错后重试成功
+	if _, err := pods.Create(context.TODO(), newPod("flaky"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Warning SyncFailed 同步出错，第1次重试: boom", "Normal Synced pod controller 出错后同步成功"} {
+		if event := nextEvent(t, recorder); event != want {
+			t.Errorf("event = %q, want %q", event, want)
+		}
+	}
+
+	// 恢复之后再次同步成功是普通的同步成功
+	pod := newPod("flaky")
+	pod.Labels = map[string]string{"app": "demo"}
+	if _, err := pods.Update(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/flaky", 3)
+	if event := nextEvent(t, recorder); event != "Normal Synced pod controller 同步成功" {
+		t.Errorf("event = %q", event)
+	}
+
+	// 对象已经被删除，没有可以记录Event的对象
+	if err := pods.Delete(context.TODO(), "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/web", 2)
+	time.Sleep(50 * time.Millisecond)
+	if n := len(recorder.Events); n != 0 {
+		t.Errorf("recorded %d events for a deleted pod", n)
+	}
+}
+
+func TestNewEventRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	// EventSinkImpl通过Events("")按Event自己的命名空间创建，fake clientset会把它当作集群级别的请求拒绝
+	client.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		event := action.(k8stesting.CreateAction).GetObject().(*corev1.Event)
+		return true, event, client.Tracker().Create(corev1.SchemeGroupVersion.WithResource("events"), event, event.Namespace)
+	})
+	recorder, stop := NewEventRecorder(client, "test-controller")
+	defer stop()
+
+	pod := newPod("web")
+	pod.UID = "web-uid"
+	recorder.Eventf(pod, corev1.EventTypeWarning, ReasonDeadLetter, "重试%d次后放弃同步", 5)
+
+	var events *corev1.EventList
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		var err error
+		events, err = client.CoreV1().Events("default").List(context.TODO(), metav1.ListOptions{})
+		return err == nil && len(events.Items) == 1, err
+	})
+	if err != nil {
+		t.Fatalf("event not written: %v", err)
+	}
+	event := events.Items[0]
+	if event.Reason != ReasonDeadLetter || event.Type != corev1.EventTypeWarning || event.Message != "重试5次后放弃同步" ||
+		event.Source.Component != "test-controller" ||
+		event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != "web" || event.InvolvedObject.UID != "web-uid" {
+		t.Errorf("event = %+v", event)
+	}
+}
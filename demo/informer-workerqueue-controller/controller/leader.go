@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+	"os"
+	"time"
+)
+
+// 选举使用coordination.k8s.io/v1的Lease
+type LeaderElectionConfig struct {
+	// 非leader等待多久后可以抢占lease
+	LeaseDuration time.Duration
+	// leader在这个时间内续约失败就放弃leader
+	RenewDeadline time.Duration
+	// 尝试获取和续约的间隔
+	RetryPeriod time.Duration
+	// Lease的命名空间和名字
+	ResourceNamespace string
+	ResourceName      string
+	// 候选者的标识，为空时使用hostname加随机后缀
+	Identity string
+}
+
+// 和kube-controller-manager的默认值一致
+func DefaultLeaderElectionConfig() LeaderElectionConfig {
+	return LeaderElectionConfig{
+		LeaseDuration:     15 * time.Second,
+		RenewDeadline:     10 * time.Second,
+		RetryPeriod:       2 * time.Second,
+		ResourceNamespace: "default",
+		ResourceName:      "informer-workerqueue-controller",
+	}
+}
+
+// ctx取消后停止时，排空队列的时间不能超过它：停止续约后lease最多还有这么久才过期，
+// 之前其他候选者不会接管，不会同时有两个实例在处理
+func (c LeaderElectionConfig) MaxGracePeriod() time.Duration {
+	return c.LeaseDuration - c.RenewDeadline
+}
+
+// 参与选举，成为leader后调用run，失去leader或ctx取消时取消run的ctx，等run返回后才返回。
+// 失去leader时关闭lost，run应该立即停止；ctx取消时run可以排空队列，
+// 但不能超过MaxGracePeriod，run返回后主动释放lease，其他候选者可以立即接管
+func RunWithLeaderElection(ctx context.Context, client kubernetes.Interface, config LeaderElectionConfig, run func(ctx context.Context, lost <-chan struct{})) error {
+	identity := config.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		identity = hostname + "_" + string(uuid.NewUUID())
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: config.ResourceNamespace, Name: config.ResourceName},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	// elector在单独的goroutine中调用OnStartedLeading，run改为在当前goroutine中执行，
+	// 这样是否运行过run、run是否已经返回都是确定的
+	leading := make(chan context.Context, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: config.LeaseDuration,
+		RenewDeadline: config.RenewDeadline,
+		RetryPeriod:   config.RetryPeriod,
+		// elector在停止续约时就释放lease，这时run可能还在排空队列，改为run返回后释放
+		ReleaseOnCancel: false,
+		Name:            config.ResourceName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("%s 成为leader", identity)
+				leading <- ctx
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("%s 不再是leader", identity)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					klog.Infof("当前leader是 %s", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("leader election: %w", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		elector.Run(ctx)
+	}()
+
+	select {
+	case <-stopped:
+		// 获取lease之前ctx就取消了
+	case leaderCtx := <-leading:
+		// elector已经停止时不再运行
+		if leaderCtx.Err() == nil {
+			// elector停止续约且ctx没有取消，说明失去了leader
+			lost := make(chan struct{})
+			go func() {
+				<-stopped
+				if ctx.Err() == nil {
+					close(lost)
+				}
+			}()
+			run(leaderCtx, lost)
+		}
+		<-stopped
+	}
+
+	// 失去leader时lease已经属于别人，不需要释放
+	if ctx.Err() != nil {
+		release(lock, identity)
+	}
+	return nil
+}
+
+// 和elector的ReleaseOnCancel一样，lease仍然属于自己时把它改成1秒后过期。
+// 没有成为过leader时lease可能不存在或属于别人，不做处理
+func release(lock *resourcelock.LeaseLock, identity string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	record, _, err := lock.Get(ctx)
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		klog.Errorf("释放lease失败: %v", err)
+		return
+	}
+	if record.HolderIdentity != identity {
+		return
+	}
+	now := metav1.Now()
+	err = lock.Update(ctx, resourcelock.LeaderElectionRecord{
+		LeaderTransitions:    record.LeaderTransitions,
+		LeaseDurationSeconds: 1,
+		RenewTime:            now,
+		AcquireTime:          now,
+	})
+	if err != nil {
+		klog.Errorf("释放lease失败: %v", err)
+		return
+	}
+	klog.Infof("%s 释放了lease", identity)
+}
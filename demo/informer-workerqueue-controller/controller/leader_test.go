@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 一个候选的controller实例：自己的informer、controller和reconciler，共享同一个clientset
+type candidate struct {
+	recorder *recorder
+	leading  int32
+	stopped  chan struct{}
+	cancel   context.CancelFunc
+}
+
+func startCandidate(t *testing.T, client *fake.Clientset, identity string) *candidate {
+	r := newRecorder()
+	return startCandidateWith(t, client, identity, r, r)
+}
+
+// reconciler处理key，recorder只用来在候选者之间区分
+func startCandidateWith(t *testing.T, client *fake.Clientset, identity string, r *recorder, reconciler Reconciler) *candidate {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	c := New("pod", factory.Core().V1().Pods().Informer(), reconciler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	factory.Start(ctx.Done())
+
+	config := DefaultLeaderElectionConfig()
+	config.LeaseDuration = time.Second
+	config.RenewDeadline = 500 * time.Millisecond
+	config.RetryPeriod = 100 * time.Millisecond
+	config.Identity = identity
+
+	cand := &candidate{recorder: r, stopped: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(cand.stopped)
+		err := RunWithLeaderElection(ctx, client, config, func(ctx context.Context, lost <-chan struct{}) {
+			atomic.StoreInt32(&cand.leading, 1)
+			defer atomic.StoreInt32(&cand.leading, 0)
+			c.RunWithAbort(ctx, lost, 2)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	return cand
+}
+
+func (c *candidate) isLeading() bool {
+	return atomic.LoadInt32(&c.leading) == 1
+}
+
+func waitLeader(t *testing.T, candidates ...*candidate) *candidate {
+	t.Helper()
+	var leader *candidate
+	err := wait.PollImmediate(20*time.Millisecond, 10*time.Second, func() (bool, error) {
+		for _, c := range candidates {
+			if c.isLeading() {
+				leader = c
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal("no leader elected")
+	}
+	return leader
+}
+
+func TestLeaderElection(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	a := startCandidate(t, client, "a")
+	b := startCandidate(t, client, "b")
+
+	leader := waitLeader(t, a, b)
+	standby := b
+	if leader == b {
+		standby = a
+	}
+
+	// 只有leader处理key
+	if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod("first"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	leader.recorder.wait(t, "default/first", 1)
+	time.Sleep(200 * time.Millisecond)
+	if n := standby.recorder.count("default/first"); n != 0 || standby.isLeading() {
+		t.Errorf("standby reconciled %d times", n)
+	}
+
+	// 其他候选者占用了lease（例如leader和apiserver之间网络中断），leader续约失败后停止worker
+	lease, err := client.CoordinationV1().Leases("default").Get(context.TODO(), "informer-workerqueue-controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := "other"
+	lease.Spec.HolderIdentity = &other
+	renewTime := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &renewTime
+	if _, err := client.CoordinationV1().Leases("default").Update(context.TODO(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-leader.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader did not stop after losing the lease")
+	}
+	if leader.isLeading() {
+		t.Error("workers still running after losing leadership")
+	}
+
+	// lease过期后备用的实例接管，之前的leader不再处理key
+	if waitLeader(t, standby) != standby {
+		t.Fatal("standby did not take over")
+	}
+	if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod("second"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	standby.recorder.wait(t, "default/second", 1)
+	if n := leader.recorder.count("default/second"); n != 0 {
+		t.Errorf("old leader reconciled %d times after losing leadership", n)
+	}
+}
+
+// ctx取消时释放lease，其他候选者不用等lease过期
+func TestLeaderElectionRelease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	a := startCandidate(t, client, "a")
+	if waitLeader(t, a) != a {
+		t.Fatal("a is not leader")
+	}
+	a.cancel()
+	<-a.stopped
+
+	lease, err := client.CoordinationV1().Leases("default").Get(context.TODO(), "informer-workerqueue-controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder := lease.Spec.HolderIdentity; holder != nil && *holder != "" {
+		t.Errorf("lease still held by %s", *holder)
+	}
+
+	b := startCandidate(t, client, "b")
+	start := time.Now()
+	waitLeader(t, b)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("took %v to take over a released lease", elapsed)
+	}
+}
+
+// Reconcile阻塞到release关闭或ctx取消，started在开始处理时关闭
+func blockingReconciler(r *recorder, started, release chan struct{}) Reconciler {
+	var once sync.Once
+	return ReconcilerFunc(func(ctx context.Context, key string) (Result, error) {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return r.Reconcile(ctx, key)
+	})
+}
+
+func holder(t *testing.T, client *fake.Clientset) string {
+	t.Helper()
+	lease, err := client.CoordinationV1().Leases("default").Get(context.TODO(), "informer-workerqueue-controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// 排空队列期间仍然持有lease，run返回后才释放
+func TestLeaderElectionReleaseAfterDrain(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	started, release := make(chan struct{}), make(chan struct{})
+	r := newRecorder()
+	a := startCandidateWith(t, client, "a", r, blockingReconciler(r, started, release))
+	if waitLeader(t, a) != a {
+		t.Fatal("a is not leader")
+	}
+	if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod("slow"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	a.cancel()
+	time.Sleep(300 * time.Millisecond)
+	if h := holder(t, client); h != "a" {
+		t.Errorf("lease held by %q while draining, want a", h)
+	}
+	select {
+	case <-a.stopped:
+		t.Fatal("returned before the in-flight key finished")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-a.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not stop after draining")
+	}
+	if n := r.count("default/slow"); n != 1 {
+		t.Errorf("default/slow reconciled %d times, want 1", n)
+	}
+	if h := holder(t, client); h != "" {
+		t.Errorf("lease still held by %s", h)
+	}
+}
+
+// 失去leader时不等宽限期（默认30秒），正在处理的key被取消
+func TestLeaderElectionLostAborts(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	started := make(chan struct{})
+	r := newRecorder()
+	a := startCandidateWith(t, client, "a", r, blockingReconciler(r, started, make(chan struct{})))
+	if waitLeader(t, a) != a {
+		t.Fatal("a is not leader")
+	}
+	if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod("slow"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	lease, err := client.CoordinationV1().Leases("default").Get(context.TODO(), "informer-workerqueue-controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := "other"
+	lease.Spec.HolderIdentity = &other
+	if _, err := client.CoordinationV1().Leases("default").Update(context.TODO(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-a.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not stop after losing the lease")
+	}
+	if h := holder(t, client); h != "other" {
+		t.Errorf("lease held by %q, want other", h)
+	}
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"net/http"
+	"time"
+)
+
+// Reconcile的结果，作为指标的result标签
+const (
+	resultSuccess      = "success"
+	resultError        = "error"
+	resultRequeue      = "requeue"
+	resultRequeueAfter = "requeue_after"
+)
+
+// 所有指标都注册到这里，由NewMetricsHandler输出
+var Registry = prometheus.NewRegistry()
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "controller",
+		Name:      "reconcile_total",
+		Help:      "Total number of reconciliations per controller and result",
+	}, []string{"controller", "result"})
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "controller",
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of reconciliations per controller and result",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"controller", "result"})
+)
+
+// workqueue的指标，名字和kube-controller-manager一致，name标签是queue的名字，即controller的名字
+var (
+	depth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "depth",
+		Help:      "Current depth of workqueue",
+	}, []string{"name"})
+	adds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "workqueue",
+		Name:      "adds_total",
+		Help:      "Total number of adds handled by workqueue",
+	}, []string{"name"})
+	latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "workqueue",
+		Name:      "queue_duration_seconds",
+		Help:      "How long in seconds an item stays in workqueue before being requested",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+	workDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "workqueue",
+		Name:      "work_duration_seconds",
+		Help:      "How long in seconds processing an item from workqueue takes",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+	unfinished = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "unfinished_work_seconds",
+		Help:      "How many seconds of work has been done that is in progress and hasn't been observed by work_duration",
+	}, []string{"name"})
+	longestRunningProcessor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "longest_running_processor_seconds",
+		Help:      "How many seconds has the longest running processor for workqueue been running",
+	}, []string{"name"})
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "workqueue",
+		Name:      "retries_total",
+		Help:      "Total number of retries handled by workqueue",
+	}, []string{"name"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		reconcileTotal, reconcileDuration,
+		depth, adds, latency, workDuration, unfinished, longestRunningProcessor, retries,
+	)
+	// 只对之后创建的queue生效，所以在init中设置
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// 实现workqueue.MetricsProvider，prometheus的指标类型已经满足workqueue要求的接口
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return depth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return adds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return latency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return unfinished.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return longestRunningProcessor.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return retries.WithLabelValues(name)
+}
+
+// 记录一次Reconcile的结果和耗时
+func observeReconcile(controller string, result Result, err error, duration time.Duration) {
+	label := resultSuccess
+	switch {
+	case err != nil:
+		label = resultError
+	case result.RequeueAfter > 0:
+		label = resultRequeueAfter
+	case result.Requeue:
+		label = resultRequeue
+	}
+	reconcileTotal.WithLabelValues(controller, label).Inc()
+	reconcileDuration.WithLabelValues(controller, label).Observe(duration.Seconds())
+}
+
+// /metrics输出Prometheus文本格式的指标，/healthz在进程存活时返回ok，
+// /readyz在ready返回true（例如informer的缓存已同步）后才返回ok，否则返回503。
+// 返回ServeMux，调用方可以继续添加其他调试路径
+func NewMetricsHandler(ready cache.InformerSynced) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready() {
+			http.Error(w, "informer cache not synced", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
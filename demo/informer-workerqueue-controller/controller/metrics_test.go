@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	r := newRecorder()
+	r.results["default/broken"] = []reconcileResult{{err: errors.New("boom")}}
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	// 指标是全局的，使用单独的名字避免和其他测试混在一起
+	c := New("metrics-test", factory.Core().V1().Pods().Informer(), r)
+	handler := NewMetricsHandler(c.HasSynced)
+
+	if code, _ := get(t, handler, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before sync = %d, want 503", code)
+	}
+	if code, body := get(t, handler, "/healthz"); code != http.StatusOK || body != "ok" {
+		t.Errorf("/healthz = %d %q", code, body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	go c.Run(ctx, 2)
+
+	for _, name := range []string{"ok", "broken"} {
+		if _, err := client.CoreV1().Pods("default").Create(context.TODO(), newPod(name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// broken出错一次后重试成功
+	r.wait(t, "default/ok", 1)
+	r.wait(t, "default/broken", 2)
+
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		code, _ := get(t, handler, "/readyz")
+		return code == http.StatusOK, nil
+	}); err != nil {
+		t.Error("/readyz not ok after sync")
+	}
+
+	var body string
+	// worker调用Done之后work_duration才会更新
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, body = get(t, handler, "/metrics")
+		return strings.Contains(body, `workqueue_work_duration_seconds_count{name="metrics-test"} 3`), nil
+	}); err != nil {
+		t.Errorf("work duration not recorded:\n%s", body)
+	}
+	for _, want := range []string{
+		`workqueue_adds_total{name="metrics-test"} 3`,
+		`workqueue_retries_total{name="metrics-test"} 1`,
+		`workqueue_depth{name="metrics-test"} 0`,
+		`workqueue_queue_duration_seconds_count{name="metrics-test"} 3`,
+		`controller_reconcile_total{controller="metrics-test",result="success"} 2`,
+		`controller_reconcile_total{controller="metrics-test",result="error"} 1`,
+		`controller_reconcile_duration_seconds_count{controller="metrics-test",result="success"} 2`,
+		`controller_reconcile_duration_seconds_count{controller="metrics-test",result="error"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+}
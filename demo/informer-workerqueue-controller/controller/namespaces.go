@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"strings"
+	"time"
+)
+
+// 逗号分隔的命名空间，去掉空白和重复的命名空间。为空时返回metav1.NamespaceAll，即所有命名空间
+func ParseNamespaces(s string) []string {
+	var namespaces []string
+	seen := map[string]bool{}
+	for _, namespace := range strings.Split(s, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+	if len(namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return namespaces
+}
+
+// 每个命名空间创建一个SharedInformerFactory，其中informer的list、watch都带上标签选择器和字段选择器，
+// 只缓存选中的对象。选择器为空时不过滤，格式错误时返回错误
+func NewNamespacedFactories(client kubernetes.Interface, resync time.Duration, namespaces []string, labelSelector, fieldSelector string) ([]informers.SharedInformerFactory, error) {
+	if _, err := labels.Parse(labelSelector); err != nil {
+		return nil, fmt.Errorf("标签选择器 %q: %w", labelSelector, err)
+	}
+	if _, err := fields.ParseSelector(fieldSelector); err != nil {
+		return nil, fmt.Errorf("字段选择器 %q: %w", fieldSelector, err)
+	}
+	tweak := func(options *metav1.ListOptions) {
+		options.LabelSelector = labelSelector
+		options.FieldSelector = fieldSelector
+	}
+
+	var factories []informers.SharedInformerFactory
+	for _, namespace := range namespaces {
+		factories = append(factories, informers.NewSharedInformerFactoryWithOptions(client, resync,
+			informers.WithNamespace(namespace), informers.WithTweakListOptions(tweak)))
+	}
+	return factories, nil
+}
+
+// 同一种资源按命名空间分别创建的informer的缓存，同一个key只会出现在其中一个缓存中
+type MultiStore []cache.Indexer
+
+// 依次在每个缓存中查找key
+func (s MultiStore) GetByKey(key string) (interface{}, bool, error) {
+	for _, store := range s {
+		obj, exists, err := store.GetByKey(key)
+		if err != nil || exists {
+			return obj, exists, err
+		}
+	}
+	return nil, false, nil
+}
+
+// 合并每个缓存中索引值为value的对象，缓存之间没有重复的对象
+func (s MultiStore) ByIndex(indexName, value string) ([]interface{}, error) {
+	var result []interface{}
+	for _, store := range s {
+		items, err := store.ByIndex(indexName, value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
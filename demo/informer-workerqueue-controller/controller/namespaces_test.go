@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	tests := map[string][]string{
+		"":                 {metav1.NamespaceAll},
+		" , ":              {metav1.NamespaceAll},
+		"default":          {"default"},
+		"a, b,a,,kube-dns": {"a", "b", "kube-dns"},
+	}
+	for s, want := range tests {
+		if got := ParseNamespaces(s); fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+			t.Errorf("ParseNamespaces(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestNewNamespacedFactories(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var mu sync.Mutex
+	var lists []string
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		restrictions := action.(k8stesting.ListAction).GetListRestrictions()
+		mu.Lock()
+		lists = append(lists, fmt.Sprintf("%s %s %s", action.GetNamespace(), restrictions.Labels, restrictions.Fields))
+		mu.Unlock()
+		return false, nil, nil
+	})
+
+	factories, err := NewNamespacedFactories(client, 0, []string{"a", "b"}, "app=web", "spec.nodeName=node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(factories) != 2 {
+		t.Fatalf("%d factories, want 2", len(factories))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var synced []cache.InformerSynced
+	for _, factory := range factories {
+		synced = append(synced, factory.Core().V1().Pods().Informer().HasSynced)
+		factory.Start(ctx.Done())
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		t.Fatal("cache not synced")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(lists)
+	want := "[a app=web spec.nodeName=node-1 b app=web spec.nodeName=node-1]"
+	if fmt.Sprint(lists) != want {
+		t.Errorf("lists = %v, want %v", lists, want)
+	}
+
+	for _, selectors := range [][2]string{{"app in (web", ""}, {"", "spec.nodeName"}} {
+		if _, err := NewNamespacedFactories(client, 0, []string{"a"}, selectors[0], selectors[1]); err == nil {
+			t.Errorf("selectors %q: want error", selectors)
+		}
+	}
+}
+
+func TestMultiStore(t *testing.T) {
+	a := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	b := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pod := newPod("web")
+	pod.Namespace = "b"
+	if err := b.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	stores := MultiStore{a, b}
+	if obj, exists, err := stores.GetByKey("b/web"); err != nil || !exists || obj != pod {
+		t.Errorf("GetByKey(b/web) = %v, %v, %v", obj, exists, err)
+	}
+	if _, exists, err := stores.GetByKey("a/web"); err != nil || exists {
+		t.Errorf("GetByKey(a/web) = %v, %v", exists, err)
+	}
+}
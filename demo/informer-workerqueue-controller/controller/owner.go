@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// 把次要资源的对象映射成需要同步的主资源key（namespace/name）
+type MapFunc func(obj interface{}) []string
+
+// 次要资源由主资源直接控制，例如Deployment拥有的ReplicaSet：
+// 按controller ownerReference找到owner类型的主资源
+func OwnerKeys(owner schema.GroupKind) MapFunc {
+	return func(obj interface{}) []string {
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			runtime.HandleError(err)
+			return nil
+		}
+		ref := controllerOf(object, owner)
+		if ref == nil {
+			return nil
+		}
+		return []string{key(object.GetNamespace(), ref.Name)}
+	}
+}
+
+// 次要资源通过中间资源间接属于主资源，例如Deployment -> ReplicaSet -> Pod：
+// 先找到控制它的中间资源，再从中间资源的缓存中找到控制中间资源的主资源
+func OwnerKeysVia(owner, intermediate schema.GroupKind, intermediateIndexer cache.Indexer) MapFunc {
+	return func(obj interface{}) []string {
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			runtime.HandleError(err)
+			return nil
+		}
+		ref := controllerOf(object, intermediate)
+		if ref == nil {
+			return nil
+		}
+		item, exists, err := intermediateIndexer.GetByKey(key(object.GetNamespace(), ref.Name))
+		if err != nil || !exists {
+			return nil
+		}
+		parent, err := meta.Accessor(item)
+		// 同名的中间资源可能已经被删除重建，UID不同时不属于它
+		if err != nil || parent.GetUID() != ref.UID {
+			return nil
+		}
+		return OwnerKeys(owner)(item)
+	}
+}
+
+// 返回类型为gk的controller ownerReference，没有时返回nil
+func controllerOf(object metav1.Object, gk schema.GroupKind) *metav1.OwnerReference {
+	ref := metav1.GetControllerOf(object)
+	if ref == nil {
+		return nil
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || gv.Group != gk.Group || ref.Kind != gk.Kind {
+		return nil
+	}
+	return ref
+}
+
+func key(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"strings"
+	"testing"
+)
+
+var (
+	deploymentKind = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	replicaSetKind = schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}
+)
+
+func controllerRef(apiVersion, kind, name string, uid types.UID) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: name, UID: uid, Controller: &isController}}
+}
+
+func replicaSet(name string, uid types.UID, owners []metav1.OwnerReference) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: uid, OwnerReferences: owners}}
+}
+
+func podOwnedBy(name string, owners []metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: owners}}
+}
+
+func TestOwnerKeys(t *testing.T) {
+	notController := controllerRef("apps/v1", "Deployment", "web", "d-uid")
+	notController[0].Controller = nil
+
+	redis := &unstructured.Unstructured{}
+	redis.SetNamespace("default")
+	redis.SetName("cache")
+	redis.SetOwnerReferences(controllerRef("apps/v1", "Deployment", "web", "d-uid"))
+
+	tests := []struct {
+		name string
+		obj  interface{}
+		want string
+	}{
+		{name: "owned", obj: replicaSet("web-1", "rs-uid", controllerRef("apps/v1", "Deployment", "web", "d-uid")), want: "default/web"},
+		{name: "unstructured", obj: redis, want: "default/web"},
+		{name: "no owner", obj: replicaSet("web-1", "rs-uid", nil)},
+		{name: "not controller", obj: replicaSet("web-1", "rs-uid", notController)},
+		{name: "other kind", obj: replicaSet("web-1", "rs-uid", controllerRef("apps/v1", "StatefulSet", "web", "d-uid"))},
+		{name: "other group", obj: replicaSet("web-1", "rs-uid", controllerRef("example.com/v1", "Deployment", "web", "d-uid"))},
+		{name: "not an object", obj: "default/web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(OwnerKeys(deploymentKind)(tt.obj), ","); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnerKeysVia(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(replicaSet("web-1", "rs-uid", controllerRef("apps/v1", "Deployment", "web", "d-uid"))); err != nil {
+		t.Fatal(err)
+	}
+	mapFunc := OwnerKeysVia(deploymentKind, replicaSetKind, indexer)
+
+	tests := []struct {
+		name string
+		obj  interface{}
+		want string
+	}{
+		{name: "owned", obj: podOwnedBy("web-1-abc", controllerRef("apps/v1", "ReplicaSet", "web-1", "rs-uid")), want: "default/web"},
+		{name: "replicaset not cached", obj: podOwnedBy("web-2-abc", controllerRef("apps/v1", "ReplicaSet", "web-2", "rs2-uid"))},
+		{name: "replicaset recreated", obj: podOwnedBy("web-1-abc", controllerRef("apps/v1", "ReplicaSet", "web-1", "old-uid"))},
+		{name: "owned by job", obj: podOwnedBy("job-abc", controllerRef("batch/v1", "Job", "job", "job-uid"))},
+		{name: "standalone", obj: podOwnedBy("demo", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(mapFunc(tt.obj), ","); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Deployment为主资源，ReplicaSet、Pod变化时同步它们所属的Deployment
+func TestWatchSecondary(t *testing.T) {
+	r := newRecorder()
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	replicaSets := factory.Apps().V1().ReplicaSets()
+	c := New("deployment", factory.Apps().V1().Deployments().Informer(), r)
+	c.Watch(replicaSets.Informer(), OwnerKeys(deploymentKind))
+	c.Watch(factory.Core().V1().Pods().Informer(), OwnerKeysVia(deploymentKind, replicaSetKind, replicaSets.Informer().GetIndexer()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	go c.Run(ctx, 2)
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "d-uid"}}
+	if _, err := client.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/web", 1)
+
+	rs := replicaSet("web-1", "rs-uid", controllerRef("apps/v1", "Deployment", "web", "d-uid"))
+	if _, err := client.AppsV1().ReplicaSets("default").Create(ctx, rs, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/web", 2)
+
+	pod := podOwnedBy("web-1-abc", controllerRef("apps/v1", "ReplicaSet", "web-1", "rs-uid"))
+	if _, err := client.CoreV1().Pods("default").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/web", 3)
+
+	if err := client.CoreV1().Pods("default").Delete(ctx, "web-1-abc", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/web", 4)
+
+	// 不属于Deployment的对象不会触发同步
+	if _, err := client.CoreV1().Pods("default").Create(ctx, podOwnedBy("demo", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AppsV1().Deployments("default").Create(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "marker", Namespace: "default"}}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r.wait(t, "default/marker", 1)
+	for key, n := range map[string]int{"default/web": 4, "default/demo": 0} {
+		if got := r.count(key); got != n {
+			t.Errorf("%s reconciled %d times, want %d", key, got, n)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Pod缓存的索引名
+const (
+	PodNodeNameIndex = "spec.nodeName"
+	PodPhaseIndex    = "status.phase"
+	// 所有ownerReferences的UID，不只是controller
+	OwnerUIDIndex = "metadata.ownerReferences.uid"
+	// 每个label一个 key=value 的索引值，见LabelIndexValue
+	LabelIndex = "metadata.labels"
+)
+
+// Pod缓存使用的索引，索引不区分命名空间
+func PodIndexers() cache.Indexers {
+	return cache.Indexers{
+		PodNodeNameIndex: podNodeNameIndexFunc,
+		PodPhaseIndex:    podPhaseIndexFunc,
+		OwnerUIDIndex:    ownerUIDIndexFunc,
+		LabelIndex:       labelIndexFunc,
+	}
+}
+
+// LabelIndex中label的索引值
+func LabelIndexValue(key, value string) string {
+	return key + "=" + value
+}
+
+func podNodeNameIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a Pod", obj)
+	}
+	// 还没有调度的Pod不进入索引
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+func podPhaseIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a Pod", obj)
+	}
+	return []string{string(pod.Status.Phase)}, nil
+}
+
+func ownerUIDIndexFunc(obj interface{}) ([]string, error) {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, ref := range object.GetOwnerReferences() {
+		uids = append(uids, string(ref.UID))
+	}
+	return uids, nil
+}
+
+func labelIndexFunc(obj interface{}) ([]string, error) {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for key, value := range object.GetLabels() {
+		values = append(values, LabelIndexValue(key, value))
+	}
+	return values, nil
+}
+
+// 通过索引查询Pod的缓存，代替List全部Pod再过滤。
+// 返回的Pod来自缓存，不能修改，需要修改时先DeepCopy
+type PodCache struct {
+	indexer MultiStore
+}
+
+// 给Pod的informer添加PodIndexers中还没有的索引，必须在informer启动之前调用。
+// 每个命名空间一个informer时传入所有的informer，查询时合并它们的结果。
+// 多个PodCache可以共享同一个informer
+func NewPodCache(informers ...cache.SharedIndexInformer) (*PodCache, error) {
+	c := &PodCache{}
+	for _, informer := range informers {
+		existing := informer.GetIndexer().GetIndexers()
+		missing := cache.Indexers{}
+		for name, indexFunc := range PodIndexers() {
+			if _, ok := existing[name]; !ok {
+				missing[name] = indexFunc
+			}
+		}
+		if len(missing) > 0 {
+			if err := informer.AddIndexers(missing); err != nil {
+				return nil, err
+			}
+		}
+		c.indexer = append(c.indexer, informer.GetIndexer())
+	}
+	return c, nil
+}
+
+// 调度到nodeName上的Pod
+func (c *PodCache) PodsOnNode(nodeName string) ([]*corev1.Pod, error) {
+	return c.byIndex(PodNodeNameIndex, nodeName)
+}
+
+// ownerReferences中包含uid的Pod，例如ReplicaSet、StatefulSet、Job的Pod
+func (c *PodCache) PodsOwnedBy(uid types.UID) ([]*corev1.Pod, error) {
+	return c.byIndex(OwnerUIDIndex, string(uid))
+}
+
+// 处于phase的Pod
+func (c *PodCache) PodsInPhase(phase corev1.PodPhase) ([]*corev1.Pod, error) {
+	return c.byIndex(PodPhaseIndex, string(phase))
+}
+
+// 有label key=value的Pod
+func (c *PodCache) PodsWithLabel(key, value string) ([]*corev1.Pod, error) {
+	return c.byIndex(LabelIndex, LabelIndexValue(key, value))
+}
+
+func (c *PodCache) byIndex(indexName, value string) ([]*corev1.Pod, error) {
+	items, err := c.indexer.ByIndex(indexName, value)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*corev1.Pod, 0, len(items))
+	for _, item := range items {
+		pods = append(pods, item.(*corev1.Pod))
+	}
+	return pods, nil
+}
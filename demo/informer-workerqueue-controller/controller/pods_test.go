@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"testing"
+)
+
+var phases = []corev1.PodPhase{corev1.PodPending, corev1.PodRunning, corev1.PodSucceeded, corev1.PodFailed}
+
+// 第i个Pod：分布在100个节点、4种phase上，每10个属于同一个ReplicaSet
+func indexedPod(i int) *corev1.Pod {
+	rs := fmt.Sprintf("rs-%d", i/10)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("pod-%d", i),
+			Namespace:       "default",
+			Labels:          map[string]string{"app": fmt.Sprintf("app-%d", i%50)},
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rs, UID: types.UID(rs + "-uid")}},
+		},
+		Spec:   corev1.PodSpec{NodeName: fmt.Sprintf("node-%d", i%100)},
+		Status: corev1.PodStatus{Phase: phases[i%len(phases)]},
+	}
+}
+
+// 返回PodCache和它使用的informer，informer不启动，直接向缓存中添加n个Pod
+func newPodCache(t testing.TB, n int) (*PodCache, cache.SharedIndexInformer) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	informer := factory.Core().V1().Pods().Informer()
+	pods, err := NewPodCache(informer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := informer.GetIndexer().Add(indexedPod(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pods, informer
+}
+
+func names(pods []*corev1.Pod) []string {
+	var result []string
+	for _, pod := range pods {
+		result = append(result, pod.Name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestPodCache(t *testing.T) {
+	pods, informer := newPodCache(t, 200)
+	unscheduled := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "unscheduled", Namespace: "default"}}
+	if err := informer.GetIndexer().Add(unscheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query func() ([]*corev1.Pod, error)
+		want  []string
+	}{
+		{"on node", func() ([]*corev1.Pod, error) { return pods.PodsOnNode("node-7") }, []string{"pod-107", "pod-7"}},
+		{"owned by", func() ([]*corev1.Pod, error) { return pods.PodsOwnedBy("rs-19-uid") }, []string{
+			"pod-190", "pod-191", "pod-192", "pod-193", "pod-194", "pod-195", "pod-196", "pod-197", "pod-198", "pod-199",
+		}},
+		{"with label", func() ([]*corev1.Pod, error) { return pods.PodsWithLabel("app", "app-3") }, []string{"pod-103", "pod-153", "pod-3", "pod-53"}},
+		{"unknown node", func() ([]*corev1.Pod, error) { return pods.PodsOnNode("node-x") }, nil},
+		{"unscheduled pod has no node", func() ([]*corev1.Pod, error) { return pods.PodsOnNode("") }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(names(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", names(got), tt.want)
+			}
+		})
+	}
+
+	running, err := pods.PodsInPhase(corev1.PodRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 50 {
+		t.Errorf("%d running pods, want 50", len(running))
+	}
+
+	// 索引随缓存更新
+	pod := indexedPod(7)
+	pod.Spec.NodeName = "node-8"
+	pod.Status.Phase = corev1.PodRunning
+	if err := informer.GetIndexer().Update(pod); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pods.PodsOnNode("node-7"); fmt.Sprint(names(got)) != "[pod-107]" {
+		t.Errorf("node-7 after update = %v", names(got))
+	}
+	if got, _ := pods.PodsInPhase(corev1.PodRunning); len(got) != 51 {
+		t.Errorf("%d running pods after update, want 51", len(got))
+	}
+	if err := informer.GetIndexer().Delete(pod); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pods.PodsOnNode("node-8"); fmt.Sprint(names(got)) != "[pod-108 pod-8]" {
+		t.Errorf("node-8 after delete = %v", names(got))
+	}
+}
+
+// 同一个informer可以创建多个PodCache
+func TestNewPodCacheShared(t *testing.T) {
+	_, informer := newPodCache(t, 0)
+	if _, err := NewPodCache(informer); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const benchmarkPods = 50000
+
+// 不使用索引，遍历缓存中的全部Pod
+func linearPodsOnNode(indexer cache.Indexer, nodeName string) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for _, item := range indexer.List() {
+		if pod := item.(*corev1.Pod); pod.Spec.NodeName == nodeName {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+func linearPodsOwnedBy(indexer cache.Indexer, uid types.UID) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for _, item := range indexer.List() {
+		pod := item.(*corev1.Pod)
+		for _, ref := range pod.OwnerReferences {
+			if ref.UID == uid {
+				pods = append(pods, pod)
+				break
+			}
+		}
+	}
+	return pods
+}
+
+func linearPodsInPhase(indexer cache.Indexer, phase corev1.PodPhase) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for _, item := range indexer.List() {
+		if pod := item.(*corev1.Pod); pod.Status.Phase == phase {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+func BenchmarkPodsOnNode(b *testing.B) {
+	pods, informer := newPodCache(b, benchmarkPods)
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if got, _ := pods.PodsOnNode("node-42"); len(got) != benchmarkPods/100 {
+				b.Fatalf("got %d pods", len(got))
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if got := linearPodsOnNode(informer.GetIndexer(), "node-42"); len(got) != benchmarkPods/100 {
+				b.Fatalf("got %d pods", len(got))
+			}
+		}
+	})
+}
+
+func BenchmarkPodsOwnedBy(b *testing.B) {
+	pods, informer := newPodCache(b, benchmarkPods)
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if got, _ := pods.PodsOwnedBy("rs-42-uid"); len(got) != 10 {
+				b.Fatalf("got %d pods", len(got))
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if got := linearPodsOwnedBy(informer.GetIndexer(), "rs-42-uid"); len(got) != 10 {
+				b.Fatalf("got %d pods", len(got))
+			}
+		}
+	})
+}
+
+func BenchmarkPodsInPhase(b *testing.B) {
+	pods, informer := newPodCache(b, benchmarkPods)
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if got, _ := pods.PodsInPhase(corev1.PodRunning); len(got) != benchmarkPods/4 {
+				b.Fatalf("got %d pods", len(got))
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if got := linearPodsInPhase(informer.GetIndexer(), corev1.PodRunning); len(got) != benchmarkPods/4 {
+				b.Fatalf("got %d pods", len(got))
+			}
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"encoding/json"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 输出死信的调试路径
+const DeadLetterPath = "/debug/deadletters"
+
+// 出错重试的限速配置
+type RetryConfig struct {
+	// 同一个key第n次重试等待BaseDelay*2^(n-1)，最多等待MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// 所有key共享的令牌桶，限制整体的重试速率
+	QPS   float64
+	Burst int
+	// 同一个key最多重试的次数，超过后放进死信
+	MaxRetries int
+}
+
+// 和workqueue.DefaultControllerRateLimiter一致，最多重试5次
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		BaseDelay:  5 * time.Millisecond,
+		MaxDelay:   1000 * time.Second,
+		QPS:        10,
+		Burst:      100,
+		MaxRetries: 5,
+	}
+}
+
+// 取单个key的指数退避和整体令牌桶中较长的等待时间
+func (r RetryConfig) rateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(r.BaseDelay, r.MaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(r.QPS), r.Burst)},
+	)
+}
+
+// 同workqueue.NewNamedRateLimitingQueue，延迟使用指定的clock，测试中可以用FakeClock控制重试的时间
+type rateLimitingQueue struct {
+	workqueue.DelayingInterface
+	rateLimiter workqueue.RateLimiter
+}
+
+func newRateLimitingQueue(rateLimiter workqueue.RateLimiter, name string, clock clock.WithTicker) workqueue.RateLimitingInterface {
+	return &rateLimitingQueue{
+		DelayingInterface: workqueue.NewDelayingQueueWithCustomClock(clock, name),
+		rateLimiter:       rateLimiter,
+	}
+}
+
+func (q *rateLimitingQueue) AddRateLimited(item interface{}) {
+	q.DelayingInterface.AddAfter(item, q.rateLimiter.When(item))
+}
+
+func (q *rateLimitingQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+func (q *rateLimitingQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
+
+// 重试次数用完后放弃的key
+type DeadLetter struct {
+	Key string `json:"key"`
+	// 最后一次的错误
+	Error   string    `json:"error"`
+	Retries int       `json:"retries"`
+	Time    time.Time `json:"time"`
+}
+
+// 死信集合，key再次同步成功后移除
+type deadLetters struct {
+	mu    sync.Mutex
+	items map[string]DeadLetter
+}
+
+func (d *deadLetters) add(letter DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.items == nil {
+		d.items = map[string]DeadLetter{}
+	}
+	d.items[letter.Key] = letter
+}
+
+func (d *deadLetters) remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.items, key)
+}
+
+// 按key排序
+func (d *deadLetters) list() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	letters := make([]DeadLetter, 0, len(d.items))
+	for _, letter := range d.items {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].Key < letters[j].Key })
+	return letters
+}
+
+// 同步出错还没有恢复的key，包括死信
+type failingKeys struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (f *failingKeys) add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.keys == nil {
+		f.keys = map[string]bool{}
+	}
+	f.keys[key] = true
+}
+
+// 返回key之前是否在出错
+func (f *failingKeys) remove(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failing := f.keys[key]
+	delete(f.keys, key)
+	return failing
+}
+
+// 当前所有的死信
+func (c *Controller) DeadLetters() []DeadLetter {
+	return c.deadLetters.list()
+}
+
+// 以JSON数组输出死信，挂在DeadLetterPath下
+func (c *Controller) DeadLetterHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.DeadLetters()); err != nil {
+			klog.Errorf("写入死信失败: %v", err)
+		}
+	})
+}
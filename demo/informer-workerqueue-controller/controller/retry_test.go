@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 不启动worker，测试中调用processNextItem逐个处理，配合FakeClock控制重试的时间
+func newRetryController(t *testing.T, r Reconciler, retry RetryConfig) (*Controller, *testingclock.FakeClock, *record.FakeRecorder) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	informer := factory.Core().V1().Pods().Informer()
+	if err := informer.GetIndexer().Add(newPod("broken")); err != nil {
+		t.Fatal(err)
+	}
+	clock := testingclock.NewFakeClock(time.Date(2022, 11, 17, 10, 0, 0, 0, time.UTC))
+	c := NewWithRetry("retry-test", informer, r, retry, clock)
+	recorder := record.NewFakeRecorder(10)
+	c.SetEventRecorder(recorder)
+	t.Cleanup(c.queue.ShutDown)
+	return c, clock, recorder
+}
+
+// 等待延迟的key回到队列
+func waitQueued(t *testing.T, c *Controller) {
+	t.Helper()
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.queue.Len() == 1, nil
+	}); err != nil {
+		t.Fatal("key was not requeued")
+	}
+}
+
+func TestRetryBackoffAndDeadLetter(t *testing.T) {
+	r := newRecorder()
+	for i := 1; i <= 4; i++ {
+		r.results["default/broken"] = append(r.results["default/broken"], reconcileResult{err: fmt.Errorf("boom %d", i)})
+	}
+	retry := RetryConfig{BaseDelay: time.Second, MaxDelay: 3 * time.Second, QPS: 100, Burst: 100, MaxRetries: 3}
+	c, clock, recorder := newRetryController(t, r, retry)
+	ctx := context.Background()
+
+	c.queue.Add("default/broken")
+	// 指数退避，最多等待MaxDelay
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		c.processNextItem(ctx)
+		clock.Step(delay - time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		if n := c.queue.Len(); n != 0 {
+			t.Fatalf("requeued before %v", delay)
+		}
+		clock.Step(time.Millisecond)
+		waitQueued(t, c)
+	}
+
+	// 第4次失败后不再重试
+	c.processNextItem(ctx)
+	clock.Step(time.Hour)
+	time.Sleep(20 * time.Millisecond)
+	if n := c.queue.Len(); n != 0 {
+		t.Errorf("requeued after max retries")
+	}
+	if n := c.queue.NumRequeues("default/broken"); n != 0 {
+		t.Errorf("NumRequeues = %d, want 0 after giving up", n)
+	}
+
+	letters := c.DeadLetters()
+	want := DeadLetter{Key: "default/broken", Error: "boom 4", Retries: 3, Time: clock.Now().Add(-time.Hour)}
+	if len(letters) != 1 || letters[0] != want {
+		t.Errorf("dead letters = %+v, want %+v", letters, want)
+	}
+	wantEvents := []string{
+		"Warning SyncFailed 同步出错，第1次重试: boom 1",
+		"Warning SyncFailed 同步出错，第2次重试: boom 2",
+		"Warning SyncFailed 同步出错，第3次重试: boom 3",
+		"Warning DeadLetter 重试3次后放弃同步: boom 4",
+	}
+	for _, want := range wantEvents {
+		select {
+		case event := <-recorder.Events:
+			if event != want {
+				t.Errorf("event = %q, want %q", event, want)
+			}
+		default:
+			t.Errorf("missing event %q", want)
+		}
+	}
+
+	// 对象再次变化并同步成功后移出死信
+	c.queue.Add("default/broken")
+	c.processNextItem(ctx)
+	if letters := c.DeadLetters(); len(letters) != 0 {
+		t.Errorf("dead letters = %+v after success", letters)
+	}
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	r := newRecorder()
+	r.results["default/broken"] = []reconcileResult{{err: errors.New("boom")}}
+	r.results["default/gone"] = []reconcileResult{{err: errors.New("not found")}}
+	c, clock, recorder := newRetryController(t, r, RetryConfig{BaseDelay: time.Second, MaxDelay: time.Second, QPS: 100, Burst: 100, MaxRetries: 0})
+
+	c.queue.Add("default/gone")
+	c.queue.Add("default/broken")
+	c.processNextItem(context.Background())
+	c.processNextItem(context.Background())
+
+	code, body := get(t, c.DeadLetterHandler(), DeadLetterPath)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	var letters []DeadLetter
+	if err := json.Unmarshal([]byte(body), &letters); err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 || letters[0].Key != "default/broken" || letters[1].Key != "default/gone" ||
+		letters[1].Error != "not found" || !letters[1].Time.Equal(clock.Now()) {
+		t.Errorf("dead letters = %s", body)
+	}
+
+	// default/gone不在缓存中，不记录Event
+	if n := len(recorder.Events); n != 1 {
+		t.Errorf("recorded %d events, want 1", n)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"demo/informer-workerqueue/controller"
+	"fmt"
+	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+)
+
+// 以Deployment为主资源，它拥有的ReplicaSet以及ReplicaSet拥有的Pod变化时同步Deployment
+func newDeploymentController(factory informers.SharedInformerFactory, retry controller.RetryConfig, out io.Writer) (*controller.Controller, error) {
+	deployments := factory.Apps().V1().Deployments()
+	replicaSets := factory.Apps().V1().ReplicaSets()
+	pods := factory.Core().V1().Pods()
+	podCache, err := controller.NewPodCache(pods.Informer())
+	if err != nil {
+		return nil, err
+	}
+
+	r := &deploymentReconciler{
+		deployments: deployments.Lister(),
+		replicaSets: replicaSets.Lister(),
+		pods:        podCache,
+		out:         out,
+	}
+	c := controller.NewWithRetry("deployment", deployments.Informer(), r, retry, clock.RealClock{})
+	c.Watch(replicaSets.Informer(), controller.OwnerKeys(deploymentKind))
+	c.Watch(pods.Informer(), controller.OwnerKeysVia(deploymentKind, replicaSetKind, replicaSets.Informer().GetIndexer()))
+	return c, nil
+}
+
+// Reconciler的示例实现，打印Deployment拥有的ReplicaSet和Pod的数量
+type deploymentReconciler struct {
+	deployments appslisters.DeploymentLister
+	replicaSets appslisters.ReplicaSetLister
+	pods        *controller.PodCache
+	out         io.Writer
+}
+
+func (r *deploymentReconciler) Reconcile(ctx context.Context, key string) (controller.Result, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return controller.Result{}, err
+	}
+	deployment, err := r.deployments.Deployments(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// Deployment被删除了
+		fmt.Fprintf(r.out, "Deployment %s 已经被删除了 \n", key)
+		return controller.Result{}, nil
+	}
+	if err != nil {
+		return controller.Result{}, err
+	}
+
+	replicaSets, err := r.replicaSets.ReplicaSets(namespace).List(labels.Everything())
+	if err != nil {
+		return controller.Result{}, err
+	}
+	rsCount, podCount := 0, 0
+	for _, rs := range replicaSets {
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		rsCount++
+		// 通过owner UID索引查询，不用List命名空间下的全部Pod
+		pods, err := r.pods.PodsOwnedBy(rs.UID)
+		if err != nil {
+			return controller.Result{}, err
+		}
+		for _, pod := range pods {
+			if metav1.IsControlledBy(pod, rs) {
+				podCount++
+			}
+		}
+	}
+	fmt.Fprintf(r.out, "Sync Deployment %s: %d ReplicaSets, %d Pods\n", deployment.Name, rsCount, podCount)
+	return controller.Result{}, nil
+}
@@ -1,39 +1,41 @@
 package main
 
 import (
+	"context"
+	"demo/informer-workerqueue/controller"
 	"flag"
-	"fmt"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
-	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
+	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 )
 
-type Controller struct {
-	indexer  cache.Indexer
-	informer cache.Controller
-	queue    workqueue.RateLimitingInterface
-}
-
-// 实例化Controller。
-func NewController(queue workqueue.RateLimitingInterface, indexer cache.Indexer, informer cache.Controller) *Controller {
-	return &Controller{
-		informer: informer,
-		indexer:  indexer,
-		queue:    queue,
-	}
-}
+var (
+	deploymentKind = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	replicaSetKind = schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}
+	redisKind      = schema.GroupKind{Group: "cs.handpay.cn", Kind: "Redis"}
+	// Redis自定义资源，见 demo/resource/crd/yml/crd.yaml
+	redisResource = schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}
+)
 
 var kubeconfig *string
 var namespace string
+var selector, fieldSelector string
+var resource string
+var gracePeriod time.Duration
+var metricsAddr string
+var retry = controller.DefaultRetryConfig()
+var leaderElect bool
+var leaderElection = controller.DefaultLeaderElectionConfig()
 
 func main() {
 	klog.InitFlags(nil)
@@ -42,138 +44,178 @@ func main() {
 	} else {
 		kubeconfig = flag.String("kubeconfig", "", "kubeconfig file")
 	}
-	flag.StringVar(&namespace, "namespace", "default", "命名空间")
+	flag.StringVar(&namespace, "namespace", "default", "命名空间，多个用逗号分隔，每个命名空间一个informer，为空时观察所有命名空间")
+	flag.StringVar(&selector, "selector", "", "pods的标签选择器，例如 app=nginx,tier!=db，只缓存和同步选中的Pod")
+	flag.StringVar(&fieldSelector, "field-selector", "", "pods的字段选择器，例如 spec.nodeName=node-1")
+	flag.StringVar(&resource, "resource", "pods", "主资源：pods、deployments（同时观察ReplicaSet和Pod）、redis（同时观察StatefulSet）")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "提供/metrics、/healthz、/readyz的地址，为空时不启动")
+	flag.DurationVar(&gracePeriod, "shutdown-grace-period", controller.DefaultGracePeriod, "收到SIGINT、SIGTERM后等待处理完队列中剩余key的最长时间")
+
+	flag.DurationVar(&retry.BaseDelay, "retry-base-delay", retry.BaseDelay, "同步出错后第一次重试的等待时间，之后每次翻倍")
+	flag.DurationVar(&retry.MaxDelay, "retry-max-delay", retry.MaxDelay, "单个key重试的最长等待时间")
+	flag.Float64Var(&retry.QPS, "retry-qps", retry.QPS, "所有key重试的总速率")
+	flag.IntVar(&retry.Burst, "retry-burst", retry.Burst, "所有key重试的突发数量")
+	flag.IntVar(&retry.MaxRetries, "max-retries", retry.MaxRetries, "单个key最多重试的次数，超过后放进死信，见 "+controller.DeadLetterPath)
+
+	flag.BoolVar(&leaderElect, "leader-elect", false, "运行多个副本时通过选举只让leader处理，使用coordination.k8s.io/v1 Lease")
+	flag.DurationVar(&leaderElection.LeaseDuration, "leader-elect-lease-duration", leaderElection.LeaseDuration, "非leader等待多久后可以抢占lease")
+	flag.DurationVar(&leaderElection.RenewDeadline, "leader-elect-renew-deadline", leaderElection.RenewDeadline, "leader在这个时间内续约失败就放弃leader，必须小于lease-duration")
+	flag.DurationVar(&leaderElection.RetryPeriod, "leader-elect-retry-period", leaderElection.RetryPeriod, "尝试获取和续约lease的间隔")
+	flag.StringVar(&leaderElection.ResourceNamespace, "leader-elect-resource-namespace", leaderElection.ResourceNamespace, "Lease所在的命名空间")
+	flag.StringVar(&leaderElection.ResourceName, "leader-elect-resource-name", leaderElection.ResourceName, "Lease的名字")
 
 	flag.Parse()
-	defer klog.Flush()
+	code := run()
+	klog.Flush()
+	os.Exit(code)
+}
 
+// 返回进程的退出码。出错时返回而不是调用klog.Fatal，保证defer的清理（例如刷新Event）会执行
+func run() int {
 	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
-		klog.Fatal(err)
+		klog.Error(err)
+		return 1
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		klog.Fatal(err)
+		klog.Error(err)
+		return 1
 	}
 
-	// 创建pod的list watcher
-	podListWatcher := cache.NewListWatchFromClient(clientset.CoreV1().RESTClient(), "pods", namespace, fields.Everything())
-
-	// 创建workqueue, 默认速率是10qps
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-
-	// 创建indexer和infomer
-	indexer, informer := cache.NewIndexerInformer(podListWatcher, &corev1.Pod{}, 0, cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			key, err := cache.MetaNamespaceKeyFunc(obj)
-			if err == nil {
-				queue.Add(key)
-			}
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			key, err := cache.MetaNamespaceKeyFunc(newObj)
-			if err == nil {
-				queue.Add(key)
-			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			// IndexerInformer使用一个增量队列，因此我们必须使用这个键函数进行删除。
-			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
-			if err == nil {
-				queue.Add(key)
-			}
-		},
-	}, cache.Indexers{})
-
-	controller := NewController(queue, indexer, informer)
-
-	stop := make(chan struct{})
-	defer close(stop)
-	go controller.Run(5, stop)
-
-	// 永远等待
-	select {}
-}
-
-// 负责观察和同步
-func (c *Controller) Run(workers int, stopCh chan struct{}) {
-	defer runtime.HandleCrash()
-	// 完工后让任务停下来
-	defer c.queue.ShuttingDown()
-
-	klog.Info("启动 Pod controller")
-
-	// 启动监听
-	go c.informer.Run(stopCh)
-
-	// 处理之前，等待所有涉及的缓存被同步
-	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
-		runtime.HandleError(fmt.Errorf("同步超时了"))
+	namespaces := controller.ParseNamespaces(namespace)
+	// 选择器作用于factory中所有的informer，deployments、redis还要观察ReplicaSet、Pod、StatefulSet，不能使用
+	if resource != "pods" && (len(namespaces) > 1 || selector != "" || fieldSelector != "") {
+		klog.Errorf("-resource=%s 只支持单个命名空间，不支持 -selector、-field-selector", resource)
+		return 2
 	}
-
-	// 创建多个work处理
-	for i := 0; i < workers; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
+	// 每个命名空间的内置资源的informer来自同一个factory，同一种资源只会list/watch一次
+	factories, err := controller.NewNamespacedFactories(clientset, 0, namespaces, selector, fieldSelector)
+	if err != nil {
+		klog.Error(err)
+		return 2
 	}
-
-	<-stopCh
-	klog.Info("停止 Pod controller")
-}
-
-func (c *Controller) runWorker()  {
-	for c.processNextItem() {
+	factory := factories[0]
+	// 自定义资源使用dynamic informer
+	var dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+
+	var c *controller.Controller
+	switch resource {
+	case "pods":
+		podController, err := newPodController(factories, retry, os.Stdout)
+		if err != nil {
+			klog.Error(err)
+			return 1
+		}
+		c = podController.Controller
+	case "deployments":
+		if c, err = newDeploymentController(factory, retry, os.Stdout); err != nil {
+			klog.Error(err)
+			return 1
+		}
+	case "redis":
+		dynamicClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			klog.Error(err)
+			return 1
+		}
+		dynamicFactory = dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, namespaces[0], nil)
+		c = newRedisController(factory, dynamicFactory.ForResource(redisResource), retry, os.Stdout)
+	default:
+		klog.Errorf("不支持的资源 %q", resource)
+		return 2
 	}
-}
 
-func (c *Controller) processNextItem() bool {
-	key, shutdown := c.queue.Get()
-	if shutdown {
-		return false
+	// 停止续约后lease还会保留一段时间，排空队列超过它的话新的leader可能已经开始处理
+	if leaderElect && gracePeriod > leaderElection.MaxGracePeriod() {
+		klog.Warningf("-shutdown-grace-period=%v 超过了lease-duration减去renew-deadline，改为 %v", gracePeriod, leaderElection.MaxGracePeriod())
+		gracePeriod = leaderElection.MaxGracePeriod()
+	}
+	c.SetGracePeriod(gracePeriod)
+
+	// 同步成功、重试以及放弃同步记录为主资源对象上的Event，kubectl describe可以看到
+	recorder, stopRecorder := controller.NewEventRecorder(clientset, "informer-workerqueue-controller")
+	defer stopRecorder()
+	c.SetEventRecorder(recorder)
+
+	// 收到SIGINT、SIGTERM或者metrics服务出错时取消ctx，controller排空队列后退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 启动监听，只会启动上面用到的informer
+	for _, factory := range factories {
+		factory.Start(ctx.Done())
+	}
+	if dynamicFactory != nil {
+		dynamicFactory.Start(ctx.Done())
+	}
+	metricsErr := make(chan error, 1)
+	if metricsAddr != "" {
+		go func() {
+			if err := serveMetrics(ctx, metricsAddr, c); err != nil {
+				metricsErr <- err
+				cancel()
+			}
+		}()
+	}
+	code := runController(ctx, clientset, c)
+	select {
+	case err := <-metricsErr:
+		klog.Errorf("metrics: %v", err)
+		return 1
+	default:
+		return code
 	}
-	defer c.queue.Done(key)
-
-	// 调用业务逻辑
-	err := c.syncToStdout(key.(string))
-
-	// 如果发现错误，处理错误，并重试
-	c.handleErr(err, key)
-	return true
 }
 
-
-// 业务逻辑
-func (c *Controller) syncToStdout(key string) error {
-	obj, exists, err := c.indexer.GetByKey(key)
+// 运行controller直到ctx取消或者失去leader，返回进程的退出码
+func runController(ctx context.Context, clientset kubernetes.Interface, c *controller.Controller) int {
+	if !leaderElect {
+		if err := c.Run(ctx, 5); err != nil {
+			klog.Error(err)
+			return 1
+		}
+		return 0
+	}
+	// 没有成为leader时只同步缓存，不处理key；失去leader时不排空队列，worker立即停止，
+	// 退出后由重启重新参与选举
+	var runErr error
+	err := controller.RunWithLeaderElection(ctx, clientset, leaderElection, func(ctx context.Context, lost <-chan struct{}) {
+		runErr = c.RunWithAbort(ctx, lost, 5)
+	})
 	if err != nil {
-		klog.Errorf(" get key %s from index failed：%v", key, err)
-		return err
+		klog.Error(err)
+		return 1
 	}
-
-	if !exists {
-		// Pod被删除了
-		fmt.Printf("Pod %s 已经被删除了 \n", key)
-	} else {
-		fmt.Printf("Sync/Add/Update for Pod %s\n", obj.(*corev1.Pod).GetName())
+	if runErr != nil {
+		klog.Error(runErr)
+		return 1
 	}
-	return nil
+	if ctx.Err() == nil {
+		klog.Info("失去leader，退出")
+		return 1
+	}
+	return 0
 }
 
-//处理错误
-func (c *Controller) handleErr(err error,key interface{})  {
-	if err == nil {
-		c.queue.Forget(key)
-		return
+// 提供指标、健康检查和死信，ctx取消时关闭并返回nil，监听失败时返回错误
+func serveMetrics(ctx context.Context, addr string, c *controller.Controller) error {
+	mux := controller.NewMetricsHandler(c.HasSynced)
+	mux.Handle(controller.DeadLetterPath, c.DeadLetterHandler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-
-	// 如果出现问题，这个控制器会重试5次。
-	if c.queue.NumRequeues(key) < 5 {
-		klog.Infof("同步pod %v 错误: %v", key, err)
-
-		//重新排队，稍后重新再试
-		c.queue.AddRateLimited(key)
-		return
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	klog.Infof("metrics 监听 %s", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
 	}
-
-	c.queue.Forget(key)
+	return nil
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"demo/informer-workerqueue/controller"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"net"
+	"testing"
+	"time"
+)
+
+// 地址被占用时返回错误而不是退出进程，ctx取消时正常返回
+func TestServeMetrics(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	c := controller.New("pod", factory.Core().V1().Pods().Informer(), nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := serveMetrics(context.Background(), listener.Addr().String(), c); err == nil {
+		t.Error("want error for an address in use")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveMetrics(ctx, "127.0.0.1:0", c) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serveMetrics = %v after cancel", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveMetrics did not return after cancel")
+	}
+}
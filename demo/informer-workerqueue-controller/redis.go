@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"demo/informer-workerqueue/controller"
+	"fmt"
+	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+)
+
+// 以Redis自定义资源为主资源（dynamic informer），它拥有的StatefulSet变化时同步Redis
+func newRedisController(factory informers.SharedInformerFactory, redisInformer informers.GenericInformer, retry controller.RetryConfig, out io.Writer) *controller.Controller {
+	statefulSets := factory.Apps().V1().StatefulSets()
+	r := &redisReconciler{
+		redis:        redisInformer.Lister(),
+		statefulSets: statefulSets.Lister(),
+		out:          out,
+	}
+	c := controller.NewWithRetry("redis", redisInformer.Informer(), r, retry, clock.RealClock{})
+	c.Watch(statefulSets.Informer(), controller.OwnerKeys(redisKind))
+	return c
+}
+
+// Reconciler的示例实现，打印Redis拥有的StatefulSet的就绪副本数
+type redisReconciler struct {
+	redis        cache.GenericLister
+	statefulSets appslisters.StatefulSetLister
+	out          io.Writer
+}
+
+func (r *redisReconciler) Reconcile(ctx context.Context, key string) (controller.Result, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return controller.Result{}, err
+	}
+	obj, err := r.redis.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// Redis被删除了
+		fmt.Fprintf(r.out, "Redis %s 已经被删除了 \n", key)
+		return controller.Result{}, nil
+	}
+	if err != nil {
+		return controller.Result{}, err
+	}
+	redis, err := meta.Accessor(obj)
+	if err != nil {
+		return controller.Result{}, err
+	}
+
+	statefulSet, err := r.statefulSets.StatefulSets(namespace).Get(name)
+	if apierrors.IsNotFound(err) || (err == nil && !metav1.IsControlledBy(statefulSet, redis)) {
+		fmt.Fprintf(r.out, "Sync Redis %s: 没有StatefulSet\n", name)
+		return controller.Result{}, nil
+	}
+	if err != nil {
+		return controller.Result{}, err
+	}
+	fmt.Fprintf(r.out, "Sync Redis %s: StatefulSet %d/%d ready\n", name, statefulSet.Status.ReadyReplicas, statefulSet.Status.Replicas)
+	return controller.Result{}, nil
+}
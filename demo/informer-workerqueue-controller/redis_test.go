@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"demo/informer-workerqueue/controller"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestRedisController(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{redisResource: "RedisList"})
+	factory := informers.NewSharedInformerFactory(client, 0)
+	dynamicFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	out := &syncBuffer{}
+	c := newRedisController(factory, dynamicFactory.ForResource(redisResource), controller.DefaultRetryConfig(), out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	dynamicFactory.Start(ctx.Done())
+	go c.Run(ctx, 2)
+
+	redis := &unstructured.Unstructured{}
+	redis.SetAPIVersion("cs.handpay.cn/v1")
+	redis.SetKind("Redis")
+	redis.SetNamespace("default")
+	redis.SetName("cache")
+	redis.SetUID("redis-uid")
+	if _, err := dynamicClient.Resource(redisResource).Namespace("default").Create(ctx, redis, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "Sync Redis cache: 没有StatefulSet")
+
+	// Redis拥有的StatefulSet状态变化时同步Redis
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(redis, redis.GroupVersionKind())}}}
+	statefulSet.Status.Replicas = 2
+	statefulSet.Status.ReadyReplicas = 1
+	if _, err := client.AppsV1().StatefulSets("default").Create(ctx, statefulSet, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "Sync Redis cache: StatefulSet 1/2 ready")
+
+	statefulSet.Status.ReadyReplicas = 2
+	if _, err := client.AppsV1().StatefulSets("default").UpdateStatus(ctx, statefulSet, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "Sync Redis cache: StatefulSet 2/2 ready")
+
+	if err := dynamicClient.Resource(redisResource).Namespace("default").Delete(ctx, "cache", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "Redis default/cache 已经被删除了")
+}
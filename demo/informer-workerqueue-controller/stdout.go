@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"demo/informer-workerqueue/controller"
+	"fmt"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// Pod的Controller，同时可以按节点、owner、phase等索引跨所有命名空间查询Pod
+type podController struct {
+	*controller.Controller
+	*controller.PodCache
+}
+
+// 以Pod为主资源，每个factory（通常每个命名空间一个）的Pod informer的事件合并到同一个Controller。
+// 索引在informer启动前注册，需要在factory.Start之前调用
+func newPodController(factories []informers.SharedInformerFactory, retry controller.RetryConfig, out io.Writer) (*podController, error) {
+	var podInformers []cache.SharedIndexInformer
+	var stores controller.MultiStore
+	for _, factory := range factories {
+		informer := factory.Core().V1().Pods().Informer()
+		podInformers = append(podInformers, informer)
+		stores = append(stores, informer.GetIndexer())
+	}
+	podCache, err := controller.NewPodCache(podInformers...)
+	if err != nil {
+		return nil, err
+	}
+	c := controller.NewWithRetry("pod", podInformers[0], &stdoutReconciler{indexer: stores, out: out}, retry, clock.RealClock{})
+	for _, informer := range podInformers[1:] {
+		c.AddInformer(informer)
+	}
+	return &podController{Controller: c, PodCache: podCache}, nil
+}
+
+// Reconciler的示例实现，只把pod的变化打印出来
+type stdoutReconciler struct {
+	indexer cache.KeyGetter
+	out     io.Writer
+}
+
+// 业务逻辑
+func (r *stdoutReconciler) Reconcile(ctx context.Context, key string) (controller.Result, error) {
+	obj, exists, err := r.indexer.GetByKey(key)
+	if err != nil {
+		klog.Errorf(" get key %s from index failed：%v", key, err)
+		return controller.Result{}, err
+	}
+
+	if !exists {
+		// Pod被删除了
+		fmt.Fprintf(r.out, "Pod %s 已经被删除了 \n", key)
+	} else {
+		fmt.Fprintf(r.out, "Sync/Add/Update for Pod %s\n", obj.(*corev1.Pod).GetName())
+	}
+	return controller.Result{}, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"demo/informer-workerqueue/controller"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestStdoutReconciler(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := &stdoutReconciler{indexer: indexer, out: &out}
+
+	for _, key := range []string{"default/demo", "default/gone"} {
+		if _, err := r.Reconcile(context.TODO(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "Sync/Add/Update for Pod demo\nPod default/gone 已经被删除了 \n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+// 每个命名空间一个informer，只缓存选中的Pod，事件合并到同一个Controller
+func TestPodControllerNamespaces(t *testing.T) {
+	pod := func(namespace, name, app string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod("a", "web-a", "web"),
+		pod("a", "db-a", "db"),
+		pod("b", "web-b", "web"),
+		pod("c", "web-c", "web"),
+	)
+	factories, err := controller.NewNamespacedFactories(client, 0, []string{"a", "b"}, "app=web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &syncBuffer{}
+	c, err := newPodController(factories, controller.DefaultRetryConfig(), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, factory := range factories {
+		factory.Start(ctx.Done())
+	}
+	go c.Run(ctx, 2)
+
+	out.wait(t, "Sync/Add/Update for Pod web-a\n")
+	out.wait(t, "Sync/Add/Update for Pod web-b\n")
+
+	// 索引查询合并所有命名空间的缓存
+	checkPods := func(want ...string) {
+		t.Helper()
+		onNode, err := c.PodsOnNode("node-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		running, err := c.PodsInPhase(corev1.PodRunning)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pods := range [][]*corev1.Pod{onNode, running} {
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Namespace+"/"+pod.Name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(want, ",") {
+				t.Errorf("pods = %v, want %v", names, want)
+			}
+		}
+	}
+	checkPods("a/web-a", "b/web-b")
+
+	if err := client.CoreV1().Pods("b").Delete(context.TODO(), "web-b", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out.wait(t, "Pod b/web-b 已经被删除了 \n")
+	checkPods("a/web-a")
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	for _, name := range []string{"db-a", "web-c"} {
+		if strings.Contains(out.buf.String(), name) {
+			t.Errorf("%s should not be synced:\n%s", name, out.buf.String())
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"demo/informer-workerqueue/controller"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"log"
+	"time"
+)
+
+// 事件的类型
+type eventType string
+
+const (
+	added   eventType = "ADDED"
+	updated eventType = "UPDATED"
+	deleted eventType = "DELETED"
+)
+
+// informer的一个事件，OldObject只有更新时才有
+type event struct {
+	Type      eventType
+	Object    metav1.Object
+	OldObject metav1.Object
+}
+
+// 每个命名空间一个Pod informer，来自controller.NewNamespacedFactories创建的factory，
+// list、watch都带上标签选择器和字段选择器，只缓存选中的Pod。格式错误时返回错误
+func newPodInformers(clientset kubernetes.Interface, resync time.Duration, namespaces []string, labelSelector, fieldSelector string) ([]cache.SharedIndexInformer, error) {
+	factories, err := controller.NewNamespacedFactories(clientset, resync, namespaces, labelSelector, fieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	var podInformers []cache.SharedIndexInformer
+	for _, factory := range factories {
+		podInformers = append(podInformers, factory.Core().V1().Pods().Informer())
+	}
+	return podInformers, nil
+}
+
+// 启动所有informer，把它们的事件合并到同一个channel。stopCh关闭后informer停止，channel不会关闭
+func merge(informers []cache.SharedIndexInformer, stopCh <-chan struct{}) <-chan event {
+	events := make(chan event, 100)
+	send := func(e event) {
+		select {
+		case events <- e:
+		case <-stopCh:
+		}
+	}
+	accessor := func(obj interface{}) metav1.Object {
+		// 删除时可能收到DeletedFinalStateUnknown
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			log.Printf("unexpected object %T: %v", obj, err)
+			return nil
+		}
+		return object
+	}
+
+	for _, informer := range informers {
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if object := accessor(obj); object != nil {
+					send(event{Type: added, Object: object})
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldObject, object := accessor(oldObj), accessor(newObj)
+				if oldObject != nil && object != nil {
+					send(event{Type: updated, Object: object, OldObject: oldObject})
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				if object := accessor(obj); object != nil {
+					send(event{Type: deleted, Object: object})
+				}
+			},
+		})
+		go informer.Run(stopCh)
+	}
+	return events
+}
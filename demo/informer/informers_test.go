@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sort"
+	"testing"
+	"time"
+)
+
+// 取出n个事件，格式为 类型 namespace/name
+func receive(t *testing.T, events <-chan event, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case e := <-events:
+			got = append(got, fmt.Sprintf("%s %s/%s", e.Type, e.Object.GetNamespace(), e.Object.GetName()))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %v, want %d events", got, n)
+		}
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestMerge(t *testing.T) {
+	pod := func(namespace, name, app string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}}}
+	}
+	client := fake.NewSimpleClientset(
+		pod("a", "web-a", "web"),
+		pod("a", "db-a", "db"),
+		pod("b", "web-b", "web"),
+		pod("c", "web-c", "web"),
+	)
+	informers, err := newPodInformers(client, 0, []string{"a", "b"}, "app=web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	events := merge(informers, stopCh)
+
+	// 只有a、b两个命名空间中选中的Pod
+	if got := receive(t, events, 2); fmt.Sprint(got) != "[ADDED a/web-a ADDED b/web-b]" {
+		t.Errorf("events = %v", got)
+	}
+
+	if err := client.CoreV1().Pods("b").Delete(context.TODO(), "web-b", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	updated := pod("a", "web-a", "web")
+	updated.Annotations = map[string]string{"demo": "updated"}
+	if _, err := client.CoreV1().Pods("a").Update(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, events, 2); fmt.Sprint(got) != "[DELETED b/web-b UPDATED a/web-a]" {
+		t.Errorf("events = %v", got)
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %s %s", e.Type, e.Object.GetName())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewPodInformersInvalidSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if _, err := newPodInformers(client, 0, []string{""}, "app in (web", ""); err == nil {
+		t.Error("invalid label selector: want error")
+	}
+	if _, err := newPodInformers(client, 0, []string{""}, "", "spec.nodeName"); err == nil {
+		t.Error("invalid field selector: want error")
+	}
+}
@@ -1,12 +1,10 @@
 package main
 
 import (
+	"demo/informer-workerqueue/controller"
 	"flag"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 	"log"
@@ -15,6 +13,8 @@ import (
 )
 
 var kubeconfig *string
+var namespace string
+var selector, fieldSelector string
 
 func init() {
 	if home := homedir.HomeDir(); home != "" {
@@ -22,13 +22,15 @@ func init() {
 	} else {
 		kubeconfig = flag.String("kubeconfig", "", "kubeconfig file")
 	}
+	flag.StringVar(&namespace, "namespace", "", "命名空间，多个用逗号分隔，每个命名空间一个informer，为空时监听所有命名空间")
+	flag.StringVar(&selector, "selector", "", "标签选择器，例如 app=nginx,tier!=db")
+	flag.StringVar(&fieldSelector, "field-selector", "", "字段选择器，例如 spec.nodeName=node-1,status.phase=Running")
 }
 
 func main() {
 	//----------------------config-------------
 	flag.Parse()
 
-
 	var (
 		config *rest.Config
 		err    error
@@ -50,25 +52,21 @@ func main() {
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 
-	// 创建shardinformer，目的是重复利用reflect，减少api调用的次数
-	sharedInformerFactory := informers.NewSharedInformerFactory(clientset, time.Minute)
-	informer := sharedInformerFactory.Core().V1().Pods().Informer()
-
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			mObj := obj.(metav1.Object)
-			log.Printf("New Pod Added to Store: %s", mObj.GetName())
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			oObj := oldObj.(metav1.Object)
-			nObj := newObj.(metav1.Object)
-			log.Printf("%s Pod Updated to %s", oObj.GetName(), nObj.GetName())
-		},
-		DeleteFunc: func(obj interface{}) {
-			mObj := obj.(metav1.Object)
-			log.Printf("Pod Deleted from Store: s", mObj.GetName())
-		},
-	})
-	informer.Run(stopCh)
+	// 每个命名空间创建一个shardinformer，目的是重复利用reflect，减少api调用的次数
+	informers, err := newPodInformers(clientset, time.Minute, controller.ParseNamespaces(namespace), selector, fieldSelector)
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	// 所有命名空间的事件合并成一个事件流
+	for e := range merge(informers, stopCh) {
+		switch e.Type {
+		case added:
+			log.Printf("New Pod Added to Store: %s/%s", e.Object.GetNamespace(), e.Object.GetName())
+		case updated:
+			log.Printf("%s Pod Updated to %s", e.OldObject.GetName(), e.Object.GetName())
+		case deleted:
+			log.Printf("Pod Deleted from Store: %s/%s", e.Object.GetNamespace(), e.Object.GetName())
+		}
+	}
 }
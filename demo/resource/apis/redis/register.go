@@ -0,0 +1,4 @@
+package redis
+
+// Redis自定义资源的API组，见 crd/yml/crd.yaml
+const GroupName = "cs.handpay.cn"
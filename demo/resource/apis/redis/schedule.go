@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"strings"
+	"time"
+)
+
+// spec.schedule解析后的结果，Next返回t之后的下一次执行时间，没有时返回零值
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// RFC3339时间，只在At执行一次
+type OnceSchedule struct {
+	At time.Time
+}
+
+func (s OnceSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.At) {
+		return s.At
+	}
+	return time.Time{}
+}
+
+// 解析spec.schedule：RFC3339时间（例如 2022-11-17T10:12:00Z）只执行一次，
+// 否则按标准的5段cron表达式（例如 */5 * * * *，也支持@hourly等）解析。
+// RFC3339时间的小数秒会被舍去：status中的metav1.Time只精确到秒，保留的话写入后读回来的时间总在At之前，
+// 会被当成还没执行而不断重新计算、写入status。
+// cron默认按UTC计算，不受scheduler所在机器时区的影响，可以用 CRON_TZ=Asia/Shanghai 前缀指定时区。
+// scheduler按它执行，webhook按它校验
+func ParseSchedule(schedule string) (Schedule, error) {
+	if at, err := time.Parse(time.RFC3339, schedule); err == nil {
+		return OnceSchedule{At: at.Truncate(time.Second)}, nil
+	}
+	spec := schedule
+	if !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		spec = "CRON_TZ=UTC " + spec
+	}
+	s, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q is neither an RFC3339 time nor a cron expression: %v", schedule, err)
+	}
+	return s, nil
+}
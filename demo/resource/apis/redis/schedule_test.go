@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2022, 11, 17, 10, 12, 30, 0, time.UTC)
+	tests := []struct {
+		schedule string
+		next     time.Time
+		invalid  bool
+	}{
+		{schedule: "2022-11-17T10:12:00Z"},
+		{schedule: "2022-11-17T11:00:00+01:00"},
+		{schedule: "2022-11-18T00:00:00Z", next: time.Date(2022, 11, 18, 0, 0, 0, 0, time.UTC)},
+		{schedule: "2022-11-18T00:00:00.5Z", next: time.Date(2022, 11, 18, 0, 0, 0, 0, time.UTC)},
+		{schedule: "*/5 * * * *", next: time.Date(2022, 11, 17, 10, 15, 0, 0, time.UTC)},
+		{schedule: "@hourly", next: time.Date(2022, 11, 17, 11, 0, 0, 0, time.UTC)},
+		{schedule: "0 18 * * *", next: time.Date(2022, 11, 17, 18, 0, 0, 0, time.UTC)},
+		{schedule: "CRON_TZ=Asia/Shanghai 0 18 * * *", next: time.Date(2022, 11, 17, 10, 0, 0, 0, time.UTC).Add(24 * time.Hour)},
+		{schedule: "tomorrow", invalid: true},
+		{schedule: "* * * *", invalid: true},
+		{schedule: "2022-11-17 10:12:00", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			s, err := ParseSchedule(tt.schedule)
+			if tt.invalid {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next := s.Next(from); !next.Equal(tt.next) {
+				t.Errorf("Next = %v, want %v", next, tt.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+// +groupName=cs.handpay.cn
+// +groupGoName=Redis
+
+// cs.handpay.cn/v1 Redis自定义资源的Go类型
+package v1
@@ -0,0 +1,56 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +resourceName=redis
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Redis实例，controller把它调谐成同名的StatefulSet和headless Service
+type Redis struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisSpec   `json:"spec,omitempty"`
+	Status RedisStatus `json:"status,omitempty"`
+}
+
+// spec.phase和status.phase的取值
+const (
+	PhasePending = "Pending"
+	PhaseRunning = "Running"
+	PhaseFailed  = "Failed"
+)
+
+type RedisSpec struct {
+	Schedule string `json:"schedule,omitempty"`
+	Command  string `json:"command,omitempty"`
+	// 为nil时使用1个副本
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Pending、Running或Failed
+	Phase string `json:"phase,omitempty"`
+}
+
+// controller通过status子资源写入，replicas和labelSelector供scale子资源使用
+type RedisStatus struct {
+	Replicas      int32  `json:"replicas,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Pending、Running或Failed
+	Phase   string `json:"phase,omitempty"`
+	Message string `json:"message,omitempty"`
+	// scheduler最近一次创建Job对应的计划时间
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// 下一次计划执行的时间，不会再执行时为空
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type RedisList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Redis `json:"items"`
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Redis转成unstructured，补上apiVersion和kind。
+// 没有设置的creationTimestamp和空的status不输出，保证和FromUnstructured来回转换不丢失、不增加字段
+func ToUnstructured(redis *Redis) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(redis)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	if redis.CreationTimestamp.IsZero() {
+		unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	}
+	if status, _, _ := unstructured.NestedMap(u.Object, "status"); len(status) == 0 {
+		unstructured.RemoveNestedField(u.Object, "status")
+	}
+	u.SetGroupVersionKind(SchemeGroupVersion.WithKind("Redis"))
+	return u, nil
+}
+
+// unstructured转成Redis，apiVersion和kind必须是cs.handpay.cn/v1 Redis
+func FromUnstructured(u *unstructured.Unstructured) (*Redis, error) {
+	if gvk := u.GroupVersionKind(); gvk != SchemeGroupVersion.WithKind("Redis") {
+		return nil, fmt.Errorf("%s is not a %s Redis", gvk, SchemeGroupVersion)
+	}
+	redis := &Redis{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, redis); err != nil {
+		return nil, err
+	}
+	return redis, nil
+}
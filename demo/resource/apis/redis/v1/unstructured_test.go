@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"resource-demo/manifest"
+	"testing"
+	"time"
+)
+
+func TestUnstructuredRoundTrip(t *testing.T) {
+	objects, err := manifest.Load("../../../crd/yml/example.yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withStatus := objects[0].DeepCopy()
+	withStatus.SetCreationTimestamp(metav1.NewTime(time.Date(2022, 11, 17, 10, 12, 0, 0, time.UTC)))
+	withStatus.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "uid"}})
+	if err := unstructured.SetNestedMap(withStatus.Object, map[string]interface{}{
+		"replicas":      int64(3),
+		"labelSelector": "app=redis",
+		"phase":         "Running",
+	}, "status"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []*unstructured.Unstructured{objects[0], withStatus} {
+		redis, err := FromUnstructured(u)
+		if err != nil {
+			t.Fatalf("FromUnstructured: %v", err)
+		}
+		back, err := ToUnstructured(redis)
+		if err != nil {
+			t.Fatalf("ToUnstructured: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(u.Object, back.Object) {
+			t.Errorf("round trip changed the object:\n%v\n%v", u.Object, back.Object)
+		}
+	}
+
+	redis, _ := FromUnstructured(objects[0])
+	if redis.Name != "example-redis" || redis.Spec.Replicas == nil || *redis.Spec.Replicas != 3 || redis.Spec.Command != "echo redis crd!" {
+		t.Errorf("unexpected redis: %+v", redis)
+	}
+}
+
+func TestFromUnstructuredWrongKind(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	if _, err := FromUnstructured(u); err == nil {
+		t.Error("want error for a ConfigMap")
+	}
+}
+
+func TestScheme(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	gvks, _, err := scheme.ObjectKinds(&RedisList{})
+	if err != nil || gvks[0] != SchemeGroupVersion.WithKind("RedisList") {
+		t.Errorf("ObjectKinds = %v, %v", gvks, err)
+	}
+
+	replicas := int32(2)
+	redis := &Redis{Spec: RedisSpec{Replicas: &replicas}}
+	copied := redis.DeepCopy()
+	*copied.Spec.Replicas = 5
+	if *redis.Spec.Replicas != 2 {
+		t.Error("DeepCopy shares spec.replicas")
+	}
+}
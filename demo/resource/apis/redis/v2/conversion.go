@@ -0,0 +1,149 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	redisv1 "resource-demo/apis/redis/v1"
+	"strings"
+)
+
+// 对方版本没有对应字段时，用这些annotation保存原值，转换回来时恢复，保证来回转换不丢失
+const (
+	// v1中保存v2的spec.requests，JSON格式
+	RequestsAnnotation = "redis.cs.handpay.cn/v2-requests"
+	// v1中保存v2的spec.command（JSON数组），参数含有空白或为空时无法从空格拼接的字符串还原
+	CommandAnnotation = "redis.cs.handpay.cn/v2-command"
+	// v2中保存v1的spec.command，原字符串含有多余空白时无法从参数列表还原
+	V1CommandAnnotation = "redis.cs.handpay.cn/v1-command"
+)
+
+// v1转成v2
+func FromV1(in *redisv1.Redis) (*Redis, error) {
+	out := &Redis{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec: RedisSpec{
+			Schedule: in.Spec.Schedule,
+			Phase:    Phase(in.Spec.Phase),
+		},
+		Status: RedisStatus{
+			Replicas:         in.Status.Replicas,
+			LabelSelector:    in.Status.LabelSelector,
+			Phase:            Phase(in.Status.Phase),
+			Message:          in.Status.Message,
+			LastScheduleTime: in.Status.LastScheduleTime.DeepCopy(),
+			NextScheduleTime: in.Status.NextScheduleTime.DeepCopy(),
+		},
+	}
+	out.APIVersion = SchemeGroupVersion.String()
+	if in.Spec.Replicas != nil {
+		replicas := *in.Spec.Replicas
+		out.Spec.Replicas = &replicas
+	}
+
+	if value, ok := popAnnotation(&out.ObjectMeta.Annotations, RequestsAnnotation); ok {
+		if err := json.Unmarshal([]byte(value), &out.Spec.Requests); err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %v", RequestsAnnotation, err)
+		}
+	}
+
+	// 保存的参数列表和当前的command一致时才使用，command可能在v1中被修改过
+	var command []string
+	if value, ok := popAnnotation(&out.ObjectMeta.Annotations, CommandAnnotation); ok {
+		if err := json.Unmarshal([]byte(value), &command); err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %v", CommandAnnotation, err)
+		}
+		if strings.Join(command, " ") != in.Spec.Command {
+			command = nil
+		}
+	}
+	if command == nil && in.Spec.Command != "" {
+		command = strings.Fields(in.Spec.Command)
+		if strings.Join(command, " ") != in.Spec.Command {
+			setAnnotation(&out.ObjectMeta.Annotations, V1CommandAnnotation, in.Spec.Command)
+		}
+	}
+	out.Spec.Command = command
+	return out, nil
+}
+
+// v2转成v1
+func ToV1(in *Redis) (*redisv1.Redis, error) {
+	out := &redisv1.Redis{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec: redisv1.RedisSpec{
+			Schedule: in.Spec.Schedule,
+			Phase:    string(in.Spec.Phase),
+		},
+		Status: redisv1.RedisStatus{
+			Replicas:         in.Status.Replicas,
+			LabelSelector:    in.Status.LabelSelector,
+			Phase:            string(in.Status.Phase),
+			Message:          in.Status.Message,
+			LastScheduleTime: in.Status.LastScheduleTime.DeepCopy(),
+			NextScheduleTime: in.Status.NextScheduleTime.DeepCopy(),
+		},
+	}
+	out.APIVersion = redisv1.SchemeGroupVersion.String()
+	if in.Spec.Replicas != nil {
+		replicas := *in.Spec.Replicas
+		out.Spec.Replicas = &replicas
+	}
+
+	if len(in.Spec.Requests) > 0 {
+		requests, err := json.Marshal(in.Spec.Requests)
+		if err != nil {
+			return nil, err
+		}
+		setAnnotation(&out.ObjectMeta.Annotations, RequestsAnnotation, string(requests))
+	}
+
+	// 原字符串和参数列表一致时直接还原
+	original, ok := popAnnotation(&out.ObjectMeta.Annotations, V1CommandAnnotation)
+	if ok && equalArgs(strings.Fields(original), in.Spec.Command) {
+		out.Spec.Command = original
+		return out, nil
+	}
+	out.Spec.Command = strings.Join(in.Spec.Command, " ")
+	if !equalArgs(strings.Fields(out.Spec.Command), in.Spec.Command) {
+		command, err := json.Marshal(in.Spec.Command)
+		if err != nil {
+			return nil, err
+		}
+		setAnnotation(&out.ObjectMeta.Annotations, CommandAnnotation, string(command))
+	}
+	return out, nil
+}
+
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 取出并删除annotation，删除后为空时置为nil
+func popAnnotation(annotations *map[string]string, key string) (string, bool) {
+	value, ok := (*annotations)[key]
+	if !ok {
+		return "", false
+	}
+	delete(*annotations, key)
+	if len(*annotations) == 0 {
+		*annotations = nil
+	}
+	return value, true
+}
+
+func setAnnotation(annotations *map[string]string, key, value string) {
+	if *annotations == nil {
+		*annotations = map[string]string{}
+	}
+	(*annotations)[key] = value
+}
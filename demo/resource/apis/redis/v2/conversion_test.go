@@ -0,0 +1,133 @@
+package v2
+
+import (
+	fuzz "github.com/google/gofuzz"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/diff"
+	redisv1 "resource-demo/apis/redis/v1"
+	"strings"
+	"testing"
+)
+
+// 命令中容易出问题的片段：空白、空参数
+var commandParts = []string{"redis-server", "--port", "6379", "echo redis crd2!", " ", "  ", "\t", "", "a\nb"}
+
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.NewWithSeed(seed).NilChance(0.2).NumElements(0, 4).Funcs(
+		func(s *redisv1.RedisSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(s)
+			var parts []string
+			for i := c.Intn(5); i > 0; i-- {
+				parts = append(parts, commandParts[c.Intn(len(commandParts))])
+			}
+			s.Command = strings.Join(parts, "")
+		},
+		func(s *RedisSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(s)
+			s.Command = nil
+			for i := c.Intn(5); i > 0; i-- {
+				s.Command = append(s.Command, commandParts[c.Intn(len(commandParts))])
+			}
+			s.Requests = nil
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				if c.RandBool() {
+					if s.Requests == nil {
+						s.Requests = corev1.ResourceList{}
+					}
+					s.Requests[name] = *resource.NewMilliQuantity(c.Int63n(1<<20), resource.DecimalSI)
+				}
+			}
+		},
+	)
+}
+
+func TestRoundTripV1(t *testing.T) {
+	f := newFuzzer(1)
+	for i := 0; i < 2000; i++ {
+		in := &redisv1.Redis{}
+		f.Fuzz(in)
+		in.APIVersion = redisv1.SchemeGroupVersion.String()
+
+		v2, err := FromV1(in)
+		if err != nil {
+			t.Fatalf("FromV1: %v", err)
+		}
+		out, err := ToV1(v2)
+		if err != nil {
+			t.Fatalf("ToV1: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(in, out) {
+			t.Fatalf("v1 -> v2 -> v1 changed the object:\n%s", diff.ObjectReflectDiff(in, out))
+		}
+	}
+}
+
+func TestRoundTripV2(t *testing.T) {
+	f := newFuzzer(2)
+	for i := 0; i < 2000; i++ {
+		in := &Redis{}
+		f.Fuzz(in)
+		in.APIVersion = SchemeGroupVersion.String()
+
+		v1, err := ToV1(in)
+		if err != nil {
+			t.Fatalf("ToV1: %v", err)
+		}
+		out, err := FromV1(v1)
+		if err != nil {
+			t.Fatalf("FromV1: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(in, out) {
+			t.Fatalf("v2 -> v1 -> v2 changed the object:\n%s", diff.ObjectReflectDiff(in, out))
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	replicas := int32(2)
+	v1 := &redisv1.Redis{Spec: redisv1.RedisSpec{Command: "echo redis crd2!", Replicas: &replicas, Phase: "Running"}}
+	v2, err := FromV1(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(v2.Spec.Command, "|") != "echo|redis|crd2!" || v2.Spec.Phase != PhaseRunning || *v2.Spec.Replicas != 2 {
+		t.Errorf("unexpected v2 spec %+v", v2.Spec)
+	}
+	if len(v2.Annotations) != 0 {
+		t.Errorf("simple command should not need annotations: %v", v2.Annotations)
+	}
+
+	// v1没有requests，保存在annotation中
+	v2.Spec.Requests = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}
+	v2.Spec.Command = []string{"sh", "-c", "redis-server --port 6379"}
+	back, err := ToV1(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Spec.Command != "sh -c redis-server --port 6379" {
+		t.Errorf("command = %q", back.Spec.Command)
+	}
+	if back.Annotations[RequestsAnnotation] != `{"memory":"64Mi"}` || back.Annotations[CommandAnnotation] != `["sh","-c","redis-server --port 6379"]` {
+		t.Errorf("annotations = %v", back.Annotations)
+	}
+
+	// 在v1中修改了command后，annotation中旧的参数列表不再使用
+	back.Spec.Command = "redis-server --port 6380"
+	v2, err = FromV1(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(v2.Spec.Command, "|") != "redis-server|--port|6380" {
+		t.Errorf("command = %q", v2.Spec.Command)
+	}
+	if _, ok := v2.Annotations[CommandAnnotation]; ok {
+		t.Errorf("annotation %s should be removed", CommandAnnotation)
+	}
+
+	back.Annotations[RequestsAnnotation] = "{"
+	if _, err := FromV1(back); err == nil {
+		t.Error("want error for invalid requests annotation")
+	}
+}
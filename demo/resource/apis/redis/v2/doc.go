@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+// +groupName=cs.handpay.cn
+// +groupGoName=Redis
+
+// cs.handpay.cn/v2 Redis自定义资源的Go类型，存储版本仍是v1，两者之间的转换见conversion.go
+package v2
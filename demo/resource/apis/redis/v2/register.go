@@ -0,0 +1,26 @@
+package v2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"resource-demo/apis/redis"
+)
+
+var SchemeGroupVersion = schema.GroupVersion{Group: redis.GroupName, Version: "v2"}
+
+// 生成的lister需要
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+var (
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &Redis{}, &RedisList{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
@@ -0,0 +1,60 @@
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +resourceName=redis
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Redis实例，和v1相比command是参数列表，phase是枚举，并且可以指定资源请求
+type Redis struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisSpec   `json:"spec,omitempty"`
+	Status RedisStatus `json:"status,omitempty"`
+}
+
+// spec.phase和status.phase的取值
+type Phase string
+
+const (
+	PhasePending Phase = "Pending"
+	PhaseRunning Phase = "Running"
+	PhaseFailed  Phase = "Failed"
+)
+
+type RedisSpec struct {
+	Schedule string `json:"schedule,omitempty"`
+	// 容器的命令和参数，第一个元素是可执行文件
+	Command []string `json:"command,omitempty"`
+	// 为nil时使用1个副本
+	Replicas *int32 `json:"replicas,omitempty"`
+	Phase    Phase  `json:"phase,omitempty"`
+	// redis容器的资源请求
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+}
+
+// controller通过status子资源写入，replicas和labelSelector供scale子资源使用
+type RedisStatus struct {
+	Replicas      int32  `json:"replicas,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	Phase         Phase  `json:"phase,omitempty"`
+	Message       string `json:"message,omitempty"`
+	// scheduler最近一次创建Job对应的计划时间
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// 下一次计划执行的时间，不会再执行时为空
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type RedisList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Redis `json:"items"`
+}
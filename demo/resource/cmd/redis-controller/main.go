@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"resource-demo/controller"
+	"resource-demo/generated/clientset/versioned"
+	"resource-demo/generated/informers/externalversions"
+	"resource-demo/scheduler"
+	"syscall"
+	"time"
+)
+
+var kubeconfig *string
+var namespace string
+var workers int
+
+// Redis controller：把cs.handpay.cn/v1 Redis调谐成StatefulSet和headless Service，并按spec.schedule执行spec.command
+func main() {
+	klog.InitFlags(nil)
+	if home := homedir.HomeDir(); home != "" {
+		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "kubeconfig file")
+	} else {
+		kubeconfig = flag.String("kubeconfig", "", "kubeconfig file")
+	}
+	flag.StringVar(&namespace, "namespace", "", "只处理这个命名空间的Redis，为空表示所有命名空间")
+	flag.IntVar(&workers, "workers", 2, "并发处理的worker数")
+
+	flag.Parse()
+	defer klog.Flush()
+
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	redisClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 10*time.Minute, informers.WithNamespace(namespace))
+	redisInformers := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 10*time.Minute, namespace, nil)
+	c := controller.NewController(kubeClient, dynamicClient, redisInformers.ForResource(controller.RedisResource), kubeInformers)
+	// scheduler按spec.schedule创建Job执行spec.command
+	typedInformers := externalversions.NewSharedInformerFactoryWithOptions(redisClient, 10*time.Minute, externalversions.WithNamespace(namespace))
+	s := scheduler.NewScheduler(kubeClient, redisClient, typedInformers.Redis().V1().Redises(), clock.RealClock{})
+
+	// 收到SIGINT、SIGTERM时停止
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	kubeInformers.Start(stop)
+	redisInformers.Start(stop)
+	typedInformers.Start(stop)
+	go func() {
+		if err := s.Run(workers, stop); err != nil {
+			klog.Fatal(err)
+		}
+	}()
+	if err := c.Run(workers, stop); err != nil {
+		klog.Fatal(err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"k8s.io/klog/v2"
+	"resource-demo/webhook"
+)
+
+var addr string
+var certFile string
+var keyFile string
+
+// Redis的校验、默认值webhook，apiserver通过HTTPS调用
+func main() {
+	klog.InitFlags(nil)
+	flag.StringVar(&addr, "addr", ":8443", "监听地址")
+	flag.StringVar(&certFile, "tls-cert-file", "/etc/webhook/certs/tls.crt", "TLS证书，需要包含webhook Service的DNS名字")
+	flag.StringVar(&keyFile, "tls-private-key-file", "/etc/webhook/certs/tls.key", "TLS私钥")
+
+	flag.Parse()
+	defer klog.Flush()
+
+	if err := webhook.ListenAndServeTLS(addr, certFile, keyFile); err != nil {
+		klog.Fatal(err)
+	}
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// Redis自定义资源，见 crd/yml/crd.yaml
+var (
+	RedisResource = schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}
+	redisKind     = schema.GroupVersionKind{Group: "cs.handpay.cn", Version: "v1", Kind: "Redis"}
+)
+
+// 把Redis对象调谐成它拥有的StatefulSet和headless Service，并通过status子资源回写状态
+type Controller struct {
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+
+	redisLister       cache.GenericLister
+	statefulSetLister appslisters.StatefulSetLister
+	serviceLister     corelisters.ServiceLister
+	synced            []cache.InformerSynced
+
+	queue workqueue.RateLimitingInterface
+}
+
+// 实例化Controller。redisInformer来自dynamicinformer，kubeInformers用来watch StatefulSet和Service
+func NewController(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface,
+	redisInformer informers.GenericInformer, kubeInformers informers.SharedInformerFactory) *Controller {
+	statefulSetInformer := kubeInformers.Apps().V1().StatefulSets()
+	serviceInformer := kubeInformers.Core().V1().Services()
+
+	c := &Controller{
+		kubeClient:        kubeClient,
+		dynamicClient:     dynamicClient,
+		redisLister:       redisInformer.Lister(),
+		statefulSetLister: statefulSetInformer.Lister(),
+		serviceLister:     serviceInformer.Lister(),
+		synced: []cache.InformerSynced{
+			redisInformer.Informer().HasSynced,
+			statefulSetInformer.Informer().HasSynced,
+			serviceInformer.Informer().HasSynced,
+		},
+		// 创建workqueue, 默认速率是10qps
+		queue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "redis"),
+	}
+
+	redisInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueue(newObj)
+		},
+		DeleteFunc: c.enqueue,
+	})
+	// 拥有的StatefulSet、Service变化时，重新调谐它们的Redis
+	owned := cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueOwner,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueOwner(newObj)
+		},
+		DeleteFunc: c.enqueueOwner,
+	}
+	statefulSetInformer.Informer().AddEventHandler(owned)
+	serviceInformer.Informer().AddEventHandler(owned)
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	// 删除时可能收到DeletedFinalStateUnknown，必须使用这个键函数
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+func (c *Controller) enqueueOwner(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("unexpected object %T", obj))
+		return
+	}
+	owner := metav1.GetControllerOf(object)
+	if owner == nil || owner.Kind != redisKind.Kind || owner.APIVersion != redisKind.GroupVersion().String() {
+		return
+	}
+	c.queue.Add(object.GetNamespace() + "/" + owner.Name)
+}
+
+// 负责观察和同步，stopCh关闭时返回
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	// 完工后让任务停下来
+	defer c.queue.ShutDown()
+
+	klog.Info("启动 Redis controller")
+
+	// 处理之前，等待所有涉及的缓存被同步
+	if !cache.WaitForCacheSync(stopCh, c.synced...) {
+		return fmt.Errorf("同步超时了")
+	}
+
+	// 创建多个work处理
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	klog.Info("停止 Redis controller")
+	return nil
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	// 调用业务逻辑
+	err := c.sync(key.(string))
+
+	// 如果发现错误，处理错误，并重试
+	c.handleErr(err, key)
+	return true
+}
+
+// 处理错误
+func (c *Controller) handleErr(err error, key interface{}) {
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+
+	// 如果出现问题，这个控制器会重试5次。
+	if c.queue.NumRequeues(key) < 5 {
+		klog.Infof("同步redis %v 错误: %v", key, err)
+
+		// 重新排队，稍后重新再试
+		c.queue.AddRateLimited(key)
+		return
+	}
+
+	c.queue.Forget(key)
+	runtime.HandleError(fmt.Errorf("放弃同步redis %v: %v", key, err))
+}
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	redisv1 "resource-demo/apis/redis/v1"
+	"testing"
+)
+
+func newRedis(replicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cs.handpay.cn/v1",
+		"kind":       "Redis",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default", "uid": "redis-uid"},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"command":  "echo redis crd!",
+			"schedule": "2022-11-17T10:12:00Z",
+		},
+	}}
+}
+
+type fixture struct {
+	t             *testing.T
+	kubeClient    *kubefake.Clientset
+	dynamicClient *fakedynamic.FakeDynamicClient
+	redisIndexer  cache.Indexer
+	controller    *Controller
+}
+
+// kubeObjects同时写入fake clientset和informer的缓存，sync直接读缓存，不需要启动informer
+func newFixture(t *testing.T, redis *unstructured.Unstructured, kubeObjects ...runtime.Object) *fixture {
+	kubeClient := kubefake.NewSimpleClientset(kubeObjects...)
+	dynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RedisResource: "RedisList"})
+
+	redisInformer := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0).ForResource(RedisResource)
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	c := NewController(kubeClient, dynamicClient, redisInformer, kubeInformers)
+
+	if redis != nil {
+		// Redis的复数名是redis，直接写入tracker，避免fake客户端按kind猜测资源名
+		if err := dynamicClient.Tracker().Create(RedisResource, redis, redis.GetNamespace()); err != nil {
+			t.Fatal(err)
+		}
+		if err := redisInformer.Informer().GetIndexer().Add(redis); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, obj := range kubeObjects {
+		var err error
+		switch obj.(type) {
+		case *appsv1.StatefulSet:
+			err = kubeInformers.Apps().V1().StatefulSets().Informer().GetIndexer().Add(obj)
+		case *corev1.Service:
+			err = kubeInformers.Core().V1().Services().Informer().GetIndexer().Add(obj)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &fixture{
+		t:             t,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		redisIndexer:  redisInformer.Informer().GetIndexer(),
+		controller:    c,
+	}
+}
+
+func (f *fixture) statefulSet() *appsv1.StatefulSet {
+	statefulSet, err := f.kubeClient.AppsV1().StatefulSets("default").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return statefulSet
+}
+
+func (f *fixture) status() map[string]interface{} {
+	redis, err := f.dynamicClient.Resource(RedisResource).Namespace("default").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	status, _, _ := unstructured.NestedMap(redis.Object, "status")
+	return status
+}
+
+// status只能通过status子资源写入
+func (f *fixture) checkStatusSubresource() {
+	for _, action := range f.dynamicClient.Actions() {
+		if action.GetVerb() == "update" && action.GetSubresource() != "status" {
+			f.t.Errorf("redis updated without the status subresource: %v", action)
+		}
+	}
+}
+
+func (f *fixture) kubeActions(verb, resource string) int {
+	count := 0
+	for _, action := range f.kubeClient.Actions() {
+		if action.GetVerb() == verb && action.GetResource().Resource == resource {
+			count++
+		}
+	}
+	return count
+}
+
+// 已有的、属于Redis的StatefulSet
+func ownedStatefulSet(redis *unstructured.Unstructured, replicas, ready int32) *appsv1.StatefulSet {
+	spec, _ := parseSpec(redis)
+	statefulSet := newStatefulSet(redis, spec)
+	statefulSet.Spec.Replicas = &replicas
+	statefulSet.Status = appsv1.StatefulSetStatus{Replicas: ready, ReadyReplicas: ready}
+	return statefulSet
+}
+
+func TestSyncCreatesOwnedObjects(t *testing.T) {
+	f := newFixture(t, newRedis(3))
+	if err := f.controller.sync("default/test"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	statefulSet := f.statefulSet()
+	if *statefulSet.Spec.Replicas != 3 || statefulSet.Spec.ServiceName != "test" {
+		t.Errorf("statefulset spec = %+v", statefulSet.Spec)
+	}
+	if env := statefulSet.Spec.Template.Spec.Containers[0].Env; env[0].Value != "echo redis crd!" {
+		t.Errorf("env = %v", env)
+	}
+	owner := metav1.GetControllerOf(statefulSet)
+	if owner == nil || owner.Kind != "Redis" || owner.UID != "redis-uid" {
+		t.Errorf("statefulset owner = %+v", owner)
+	}
+
+	service, err := f.kubeClient.CoreV1().Services("default").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Spec.ClusterIP != corev1.ClusterIPNone || metav1.GetControllerOf(service) == nil {
+		t.Errorf("service is not an owned headless service: %+v", service)
+	}
+
+	status := f.status()
+	if status["phase"] != redisv1.PhasePending || status["replicas"] != int64(0) ||
+		status["labelSelector"] != "app=redis,cs.handpay.cn/instance=test" {
+		t.Errorf("status = %v", status)
+	}
+	f.checkStatusSubresource()
+}
+
+func TestSyncScalesStatefulSet(t *testing.T) {
+	redis := newRedis(3)
+	f := newFixture(t, redis, ownedStatefulSet(redis, 1, 1), newService(redis))
+	if err := f.controller.sync("default/test"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	if replicas := *f.statefulSet().Spec.Replicas; replicas != 3 {
+		t.Errorf("statefulset replicas = %d, want 3", replicas)
+	}
+	if n := f.kubeActions("update", "services"); n != 0 {
+		t.Errorf("service updated %d times, want unchanged", n)
+	}
+	if status := f.status(); status["phase"] != redisv1.PhasePending || status["replicas"] != int64(1) {
+		t.Errorf("status = %v", status)
+	}
+}
+
+func TestSyncRunning(t *testing.T) {
+	redis := newRedis(2)
+	f := newFixture(t, redis, ownedStatefulSet(redis, 2, 2), newService(redis))
+	if err := f.controller.sync("default/test"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	if n := f.kubeActions("update", "statefulsets"); n != 0 {
+		t.Errorf("statefulset updated %d times, want unchanged", n)
+	}
+	if status := f.status(); status["phase"] != redisv1.PhaseRunning || status["replicas"] != int64(2) {
+		t.Errorf("status = %v", status)
+	}
+
+	// 缓存中的Redis已经是最新状态时，不再写status
+	updated, err := f.dynamicClient.Resource(RedisResource).Namespace("default").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.redisIndexer.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	f.dynamicClient.ClearActions()
+	if err := f.controller.sync("default/test"); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if actions := f.dynamicClient.Actions(); len(actions) != 0 {
+		t.Errorf("unchanged status written again: %v", actions)
+	}
+}
+
+// scheduler写入的字段不能被覆盖，恢复后上次的message要清除
+func TestSyncKeepsOtherStatusFields(t *testing.T) {
+	redis := newRedis(2)
+	if err := unstructured.SetNestedMap(redis.Object, map[string]interface{}{
+		"lastScheduleTime": "2022-11-17T10:12:00Z",
+		"phase":            redisv1.PhaseFailed,
+		"message":          "old error",
+	}, "status"); err != nil {
+		t.Fatal(err)
+	}
+	f := newFixture(t, redis, ownedStatefulSet(redis, 2, 2), newService(redis))
+	if err := f.controller.sync("default/test"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	status := f.status()
+	if status["lastScheduleTime"] != "2022-11-17T10:12:00Z" || status["phase"] != redisv1.PhaseRunning {
+		t.Errorf("status = %v", status)
+	}
+	if _, ok := status["message"]; ok {
+		t.Errorf("message should be removed: %v", status)
+	}
+}
+
+func TestSyncNotOwned(t *testing.T) {
+	redis := newRedis(1)
+	statefulSet := ownedStatefulSet(redis, 1, 1)
+	statefulSet.OwnerReferences = nil
+	f := newFixture(t, redis, statefulSet, newService(redis))
+
+	if err := f.controller.sync("default/test"); err == nil {
+		t.Fatal("sync: want error for a statefulset not owned by the redis")
+	}
+	if status := f.status(); status["phase"] != redisv1.PhaseFailed || status["message"] == nil {
+		t.Errorf("status = %v", status)
+	}
+}
+
+func TestSyncDeleted(t *testing.T) {
+	f := newFixture(t, nil)
+	if err := f.controller.sync("default/test"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if n := len(f.kubeClient.Actions()) + len(f.dynamicClient.Actions()); n != 0 {
+		t.Errorf("got %d actions for a deleted redis", n)
+	}
+}
+
+func TestEnqueueOwner(t *testing.T) {
+	redis := newRedis(1)
+	f := newFixture(t, nil)
+	c := f.controller
+
+	statefulSet := ownedStatefulSet(redis, 1, 1)
+	c.enqueueOwner(statefulSet)
+	unowned := statefulSet.DeepCopy()
+	unowned.OwnerReferences = nil
+	c.enqueueOwner(unowned)
+
+	if c.queue.Len() != 1 {
+		t.Fatalf("queue length = %d, want 1", c.queue.Len())
+	}
+	if key, _ := c.queue.Get(); key != "default/test" {
+		t.Errorf("queued key = %v", key)
+	}
+}
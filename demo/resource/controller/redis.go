@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	redisv1 "resource-demo/apis/redis/v1"
+)
+
+const (
+	redisImage = "redis:6.2"
+	redisPort  = 6379
+	// spec.replicas未设置时的副本数
+	defaultReplicas = 1
+)
+
+// Redis中controller关心的字段
+type redisSpec struct {
+	Replicas int32
+	Command  string
+	Schedule string
+}
+
+func parseSpec(redis *unstructured.Unstructured) (redisSpec, error) {
+	spec := redisSpec{Replicas: defaultReplicas}
+	replicas, found, err := unstructured.NestedInt64(redis.Object, "spec", "replicas")
+	if err != nil {
+		return spec, err
+	}
+	if found {
+		spec.Replicas = int32(replicas)
+	}
+	if spec.Command, _, err = unstructured.NestedString(redis.Object, "spec", "command"); err != nil {
+		return spec, err
+	}
+	if spec.Schedule, _, err = unstructured.NestedString(redis.Object, "spec", "schedule"); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// StatefulSet、Service和pod使用的label，也是scale子资源的labelSelector
+func selectorLabels(redis *unstructured.Unstructured) map[string]string {
+	return map[string]string{
+		"app":                    "redis",
+		"cs.handpay.cn/instance": redis.GetName(),
+	}
+}
+
+func ownerReferences(redis *unstructured.Unstructured) []metav1.OwnerReference {
+	return []metav1.OwnerReference{*metav1.NewControllerRef(redis, redisKind)}
+}
+
+// headless Service，StatefulSet的pod通过它获得稳定的DNS名字
+func newService(redis *unstructured.Unstructured) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            redis.GetName(),
+			Namespace:       redis.GetNamespace(),
+			Labels:          selectorLabels(redis),
+			OwnerReferences: ownerReferences(redis),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  selectorLabels(redis),
+			Ports:     []corev1.ServicePort{{Name: "redis", Port: redisPort}},
+		},
+	}
+}
+
+func newStatefulSet(redis *unstructured.Unstructured, spec redisSpec) *appsv1.StatefulSet {
+	replicas := spec.Replicas
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            redis.GetName(),
+			Namespace:       redis.GetNamespace(),
+			Labels:          selectorLabels(redis),
+			OwnerReferences: ownerReferences(redis),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: redis.GetName(),
+			Selector:    &metav1.LabelSelector{MatchLabels: selectorLabels(redis)},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: selectorLabels(redis)},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "redis",
+						Image: redisImage,
+						Ports: []corev1.ContainerPort{{Name: "redis", ContainerPort: redisPort}},
+						// spec中的command和schedule交给容器自己处理
+						Env: []corev1.EnvVar{
+							{Name: "REDIS_COMMAND", Value: spec.Command},
+							{Name: "REDIS_SCHEDULE", Value: spec.Schedule},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// 业务逻辑：创建或更新StatefulSet和Service，然后回写status
+func (c *Controller) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	obj, err := c.redisLister.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// StatefulSet和Service有ownerReferences，由垃圾回收删除
+		klog.Infof("Redis %s 已经被删除了", key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	redis, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object %T for redis %s", obj, key)
+	}
+	spec, err := parseSpec(redis)
+	if err != nil {
+		return c.updateStatus(redis, nil, fmt.Errorf("invalid spec: %v", err))
+	}
+
+	if err := c.syncService(redis); err != nil {
+		return c.updateStatus(redis, nil, err)
+	}
+	statefulSet, err := c.syncStatefulSet(redis, spec)
+	if err != nil {
+		return c.updateStatus(redis, nil, err)
+	}
+	return c.updateStatus(redis, statefulSet, nil)
+}
+
+// 对象已存在但不属于这个Redis时不去修改它
+func checkOwner(redis *unstructured.Unstructured, object metav1.Object) error {
+	if !metav1.IsControlledBy(object, redis) {
+		return fmt.Errorf("%s/%s already exists and is not managed by redis %s", object.GetNamespace(), object.GetName(), redis.GetName())
+	}
+	return nil
+}
+
+func (c *Controller) syncService(redis *unstructured.Unstructured) error {
+	desired := newService(redis)
+	service, err := c.serviceLister.Services(redis.GetNamespace()).Get(redis.GetName())
+	if apierrors.IsNotFound(err) {
+		_, err = c.kubeClient.CoreV1().Services(redis.GetNamespace()).Create(context.TODO(), desired, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkOwner(redis, service); err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(service.Spec.Selector, desired.Spec.Selector) && portsEqual(service.Spec.Ports, desired.Spec.Ports) {
+		return nil
+	}
+	// lister中的对象是共享的，修改前先复制
+	service = service.DeepCopy()
+	service.Spec.Selector = desired.Spec.Selector
+	service.Spec.Ports = desired.Spec.Ports
+	_, err = c.kubeClient.CoreV1().Services(redis.GetNamespace()).Update(context.TODO(), service, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *Controller) syncStatefulSet(redis *unstructured.Unstructured, spec redisSpec) (*appsv1.StatefulSet, error) {
+	desired := newStatefulSet(redis, spec)
+	statefulSet, err := c.statefulSetLister.StatefulSets(redis.GetNamespace()).Get(redis.GetName())
+	if apierrors.IsNotFound(err) {
+		return c.kubeClient.AppsV1().StatefulSets(redis.GetNamespace()).Create(context.TODO(), desired, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwner(redis, statefulSet); err != nil {
+		return nil, err
+	}
+	// 只维护副本数和容器的镜像、环境变量，其余字段由apiserver填默认值，不能直接整体比较
+	containers := statefulSet.Spec.Template.Spec.Containers
+	want := desired.Spec.Template.Spec.Containers[0]
+	if equality.Semantic.DeepEqual(statefulSet.Spec.Replicas, desired.Spec.Replicas) && len(containers) == 1 &&
+		containers[0].Image == want.Image && equality.Semantic.DeepEqual(containers[0].Env, want.Env) {
+		return statefulSet, nil
+	}
+	statefulSet = statefulSet.DeepCopy()
+	statefulSet.Spec.Replicas = desired.Spec.Replicas
+	if len(statefulSet.Spec.Template.Spec.Containers) == 1 {
+		statefulSet.Spec.Template.Spec.Containers[0].Image = want.Image
+		statefulSet.Spec.Template.Spec.Containers[0].Env = want.Env
+	} else {
+		statefulSet.Spec.Template.Spec.Containers = desired.Spec.Template.Spec.Containers
+	}
+	return c.kubeClient.AppsV1().StatefulSets(redis.GetNamespace()).Update(context.TODO(), statefulSet, metav1.UpdateOptions{})
+}
+
+// 只比较端口名和端口号，protocol、targetPort由apiserver填默认值
+func portsEqual(current, desired []corev1.ServicePort) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+	for i := range current {
+		if current[i].Name != desired[i].Name || current[i].Port != desired[i].Port {
+			return false
+		}
+	}
+	return true
+}
+
+// 通过status子资源写入replicas、labelSelector和phase，kubectl scale依赖前两个字段。
+// syncErr不为nil时phase为Failed，并把syncErr返回给workqueue重试。
+// status中其他字段（例如scheduler写入的lastScheduleTime）保持不变
+func (c *Controller) updateStatus(redis *unstructured.Unstructured, statefulSet *appsv1.StatefulSet, syncErr error) error {
+	current, _, _ := unstructured.NestedMap(redis.Object, "status")
+	status := map[string]interface{}{}
+	for k, v := range current {
+		status[k] = v
+	}
+	status["labelSelector"] = labels.SelectorFromSet(selectorLabels(redis)).String()
+	status["replicas"] = int64(0)
+	status["phase"] = redisv1.PhasePending
+	delete(status, "message")
+	if statefulSet != nil {
+		status["replicas"] = int64(statefulSet.Status.Replicas)
+		desired := int32(defaultReplicas)
+		if statefulSet.Spec.Replicas != nil {
+			desired = *statefulSet.Spec.Replicas
+		}
+		if statefulSet.Status.ReadyReplicas == desired && statefulSet.Status.ObservedGeneration >= statefulSet.Generation {
+			status["phase"] = redisv1.PhaseRunning
+		}
+	}
+	if syncErr != nil {
+		status["phase"] = redisv1.PhaseFailed
+		status["message"] = syncErr.Error()
+	}
+
+	if equality.Semantic.DeepEqual(current, status) {
+		return syncErr
+	}
+	redis = redis.DeepCopy()
+	if err := unstructured.SetNestedMap(redis.Object, status, "status"); err != nil {
+		return err
+	}
+	_, err := c.dynamicClient.Resource(RedisResource).Namespace(redis.GetNamespace()).UpdateStatus(context.TODO(), redis, metav1.UpdateOptions{})
+	if syncErr != nil {
+		return syncErr
+	}
+	return err
+}
@@ -2,84 +2,89 @@ package crd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/util/retry"
 	"log"
+	redisv1 "resource-demo/apis/redis/v1"
+	"resource-demo/generated/clientset/versioned"
+	"resource-demo/query"
 )
 
 type Crd struct {
-	Dr        dynamic.ResourceInterface
+	Client    versioned.Interface
 	Name      string
 	Namespace string
-	Obj       *unstructured.Unstructured
+	Obj       *redisv1.Redis
 }
 
-
-func (c *Crd) Create() {
+func (c *Crd) Create() (*redisv1.Redis, error) {
 	//创建
-	objCreate, err := c.Dr.Create(context.TODO(), c.Obj, metav1.CreateOptions{})
+	objCreate, err := c.Client.RedisV1().Redises(c.Namespace).Create(context.TODO(), c.Obj, metav1.CreateOptions{})
 	if err != nil {
-		//panic(fmt.Errorf("Create resource ERROR: %v", err))
-		log.Print(err)
+		return nil, fmt.Errorf("create resource ERROR: %w", err)
 	}
-	log.Print("Create: : ", objCreate)
+	log.Print("Create: : ", objCreate.GetName())
+	return objCreate, nil
 }
 
-func (c *Crd) Get() {
+func (c *Crd) Get() (*redisv1.Redis, error) {
 	// 查询
-	objGET, err := c.Dr.Get(context.TODO(), "test", metav1.GetOptions{})
+	objGET, err := c.Client.RedisV1().Redises(c.Namespace).Get(context.TODO(), c.Name, metav1.GetOptions{})
 	if err != nil {
-		panic(fmt.Errorf("select resource ERROR: %v", err))
+		return nil, fmt.Errorf("select resource ERROR: %w", err)
 	}
-	jsonBytes, err := json.Marshal(objGET)
+	return objGET, nil
+}
+
+// 按selector过滤并分页取完，-A时列出所有命名空间
+func (c *Crd) List(opts query.Options) (*redisv1.RedisList, error) {
+	result := &redisv1.RedisList{}
+	err := query.Paginate(opts.ListOptions(), func(listOptions metav1.ListOptions) (string, error) {
+		list, err := c.Client.RedisV1().Redises(opts.Namespace(c.Namespace)).List(context.TODO(), listOptions)
+		if err != nil {
+			return "", err
+		}
+		result.ResourceVersion = list.ResourceVersion
+		result.Items = append(result.Items, list.Items...)
+		return list.Continue, nil
+	})
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
 	}
-	fmt.Println(string(jsonBytes))
+	return result, nil
 }
 
-func (c *Crd) Update() {
+// 用Obj的spec覆盖已有资源的spec，冲突时重试
+func (c *Crd) Update() (*redisv1.Redis, error) {
 	//更新
+	var objUpdate *redisv1.Redis
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
 		// 查询resource是否存在
-		result, getErr := c.Dr.Get(context.TODO(), c.Obj.GetName(), metav1.GetOptions{})
+		result, getErr := c.Client.RedisV1().Redises(c.Namespace).Get(context.TODO(), c.Obj.GetName(), metav1.GetOptions{})
 		if getErr != nil {
-			panic(fmt.Errorf("failed to get latest version of : %v", getErr))
+			return fmt.Errorf("failed to get latest version of : %w", getErr)
 		}
 
-		// 提取obj 的 spec 期望值
-		spec, found, err := unstructured.NestedMap(c.Obj.Object, "spec")
-		fmt.Print("get spec:", spec)
-		if err != nil || !found || spec == nil {
-			panic(fmt.Errorf(" not found or error in spec: %v", err))
-		}
 		// 更新 存在资源的spec
-		if err := unstructured.SetNestedMap(result.Object, spec, "spec"); err != nil {
-			panic(err)
-		}
+		c.Obj.Spec.DeepCopyInto(&result.Spec)
 		// 更新资源
-		objUpdate, err := c.Dr.Update(context.TODO(), result, metav1.UpdateOptions{})
-		log.Print("update : ", objUpdate)
+		objUpdate, err = c.Client.RedisV1().Redises(c.Namespace).Update(context.TODO(), result, metav1.UpdateOptions{})
 		return err
 	})
 	if retryErr != nil {
-		panic(fmt.Errorf("update failed: %v", retryErr))
-	} else {
-		log.Print("更新成功")
+		return nil, fmt.Errorf("update failed: %w", retryErr)
 	}
-
+	log.Print("更新成功")
+	return objUpdate, nil
 }
 
-func (c *Crd) Delete() {
+func (c *Crd) Delete() error {
 	//删除
-	err := c.Dr.Delete(context.TODO(), c.Obj.GetName(), metav1.DeleteOptions{})
+	err := c.Client.RedisV1().Redises(c.Namespace).Delete(context.TODO(), c.Obj.GetName(), metav1.DeleteOptions{})
 	if err != nil {
-		panic(fmt.Errorf("delete resource ERROR : %v", err))
-	} else {
-		log.Print("删除成功")
+		return fmt.Errorf("delete resource ERROR : %w", err)
 	}
+	log.Print("删除成功")
+	return nil
 }
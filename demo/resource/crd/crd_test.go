@@ -0,0 +1,152 @@
+package crd
+
+import (
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	redisv1 "resource-demo/apis/redis/v1"
+	"resource-demo/errs"
+	"resource-demo/generated/clientset/versioned/fake"
+	"resource-demo/query"
+	"testing"
+)
+
+func redis(namespace, name string, replicas int32, labels map[string]string) *redisv1.Redis {
+	return &redisv1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       redisv1.RedisSpec{Replicas: &replicas, Phase: "Running"},
+	}
+}
+
+// NewSimpleClientset会把Redis的复数猜成redises，这里用正确的resource写入tracker
+func newCrd(objects ...*redisv1.Redis) (*Crd, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	for _, obj := range objects {
+		if err := client.Tracker().Create(redisv1.SchemeGroupVersion.WithResource("redis"), obj, obj.Namespace); err != nil {
+			panic(err)
+		}
+	}
+	return &Crd{Client: client, Name: "test", Namespace: "default", Obj: redis("default", "test", 3, nil)}, client
+}
+
+func TestCrdCRUD(t *testing.T) {
+	c, _ := newCrd(redis("default", "test", 1, nil))
+	if _, err := c.Create(); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("create existing: want AlreadyExists, got %v", err)
+	}
+
+	updated, err := c.Update()
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if *updated.Spec.Replicas != 3 {
+		t.Errorf("replicas = %d, want 3", *updated.Spec.Replicas)
+	}
+
+	if err := c.Delete(); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.Get(); !apierrors.IsNotFound(err) {
+		t.Errorf("get after delete: want NotFound, got %v", err)
+	}
+	if _, err := c.Create(); err != nil {
+		t.Errorf("create: %v", err)
+	}
+}
+
+func TestCrdList(t *testing.T) {
+	c, _ := newCrd(
+		redis("default", "test", 1, map[string]string{"tier": "cache"}),
+		redis("default", "other", 1, nil),
+		redis("kube-system", "cache", 1, map[string]string{"tier": "cache"}),
+	)
+
+	tests := []struct {
+		name string
+		opts query.Options
+		want int
+	}{
+		{name: "namespace", opts: query.Options{}, want: 2},
+		{name: "label selector", opts: query.Options{LabelSelector: "tier=cache"}, want: 1},
+		{name: "all namespaces", opts: query.Options{AllNamespaces: true, LabelSelector: "tier=cache"}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := c.List(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Items) != tt.want {
+				t.Errorf("got %d items, want %d", len(list.Items), tt.want)
+			}
+		})
+	}
+}
+
+func TestCrdErrors(t *testing.T) {
+	gr := redisv1.Resource("redis")
+	tests := []struct {
+		name string
+		verb string
+		err  error
+		call func(c *Crd) error
+		want errs.Reason
+	}{
+		{
+			name: "create forbidden",
+			verb: "create",
+			err:  apierrors.NewForbidden(gr, "test", fmt.Errorf("rbac")),
+			call: func(c *Crd) error { _, err := c.Create(); return err },
+			want: errs.Forbidden,
+		},
+		{
+			name: "create invalid",
+			verb: "create",
+			err:  apierrors.NewInvalid(redisv1.SchemeGroupVersion.WithKind("Redis").GroupKind(), "test", nil),
+			call: func(c *Crd) error { _, err := c.Create(); return err },
+			want: errs.Invalid,
+		},
+		{
+			name: "get missing",
+			verb: "get",
+			err:  apierrors.NewNotFound(gr, "test"),
+			call: func(c *Crd) error { _, err := c.Get(); return err },
+			want: errs.NotFound,
+		},
+		{
+			name: "update missing",
+			verb: "get",
+			err:  apierrors.NewNotFound(gr, "test"),
+			call: func(c *Crd) error { _, err := c.Update(); return err },
+			want: errs.NotFound,
+		},
+		{
+			name: "update keeps conflicting",
+			verb: "update",
+			err:  apierrors.NewConflict(gr, "test", fmt.Errorf("object was modified")),
+			call: func(c *Crd) error { _, err := c.Update(); return err },
+			want: errs.Conflict,
+		},
+		{
+			name: "delete timeout",
+			verb: "delete",
+			err:  apierrors.NewServerTimeout(gr, "delete", 1),
+			call: func(c *Crd) error { return c.Delete() },
+			want: errs.Timeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client := newCrd(redis("default", "test", 1, nil))
+			client.PrependReactor(tt.verb, "redis", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tt.err
+			})
+			err := tt.call(c)
+			if got := errs.Classify(err); got != tt.want {
+				t.Errorf("Classify(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+}
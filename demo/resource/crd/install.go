@@ -0,0 +1,122 @@
+package crd
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/util/retry"
+	"log"
+	"resource-demo/wait"
+	"time"
+)
+
+// Redis的CustomResourceDefinition，即 yml/crd.yaml
+//
+//go:embed yml/crd.yaml
+var Definition []byte
+
+// 等待CRD就绪时查询的间隔
+var pollInterval = 500 * time.Millisecond
+
+// 解析yaml格式的CustomResourceDefinition
+func Decode(data []byte) (*apiextensionsv1.CustomResourceDefinition, error) {
+	definition := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.UnmarshalStrict(data, definition); err != nil {
+		return nil, fmt.Errorf("invalid CustomResourceDefinition: %w", err)
+	}
+	return definition, nil
+}
+
+// 设置转换webhook的CA证书（PEM格式），apiserver用它验证webhook的证书
+func SetCABundle(definition *apiextensionsv1.CustomResourceDefinition, caBundle []byte) error {
+	conversion := definition.Spec.Conversion
+	if conversion == nil || conversion.Strategy != apiextensionsv1.WebhookConverter || conversion.Webhook == nil {
+		return fmt.Errorf("%s does not use a conversion webhook", definition.Name)
+	}
+	conversion.Webhook.ClientConfig.CABundle = caBundle
+	return nil
+}
+
+// 是否通过webhook在版本之间转换
+func UsesConversionWebhook(definition *apiextensionsv1.CustomResourceDefinition) bool {
+	conversion := definition.Spec.Conversion
+	return conversion != nil && conversion.Strategy == apiextensionsv1.WebhookConverter
+}
+
+// 不使用转换webhook，只提供存储版本。没有caBundle时apiserver调用不了webhook，
+// 读写其他版本都会失败，不如只提供存储版本
+func DisableConversion(definition *apiextensionsv1.CustomResourceDefinition) {
+	definition.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{Strategy: apiextensionsv1.NoneConverter}
+	for i := range definition.Spec.Versions {
+		if !definition.Spec.Versions[i].Storage {
+			definition.Spec.Versions[i].Served = false
+		}
+	}
+}
+
+// 创建CRD，已存在时用definition的spec更新它，然后等待Established和NamesAccepted。
+// ctx超时返回wait.ErrTimeout
+func Install(ctx context.Context, client apiextensionsclientset.Interface, definition *apiextensionsv1.CustomResourceDefinition) (*apiextensionsv1.CustomResourceDefinition, error) {
+	crds := client.ApiextensionsV1().CustomResourceDefinitions()
+	_, err := crds.Create(ctx, definition, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current, err := crds.Get(ctx, definition.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			current = current.DeepCopy()
+			current.Spec = definition.Spec
+			_, err = crds.Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		})
+		if err == nil {
+			log.Print("更新CRD: ", definition.Name)
+		}
+	} else if err == nil {
+		log.Print("创建CRD: ", definition.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("install %s: %w", definition.Name, err)
+	}
+	return waitEstablished(ctx, client, definition.Name)
+}
+
+// apiserver接受了名字并开始提供服务后CRD才可用；NamesAccepted为False表示名字和其他CRD冲突，不会再变好
+func waitEstablished(ctx context.Context, client apiextensionsclientset.Interface, name string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	var crd *apiextensionsv1.CustomResourceDefinition
+	err := utilwait.PollImmediateUntilWithContext(ctx, pollInterval, func(ctx context.Context) (bool, error) {
+		var err error
+		crd, err = client.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		established, namesAccepted := false, false
+		for _, condition := range crd.Status.Conditions {
+			switch condition.Type {
+			case apiextensionsv1.Established:
+				established = condition.Status == apiextensionsv1.ConditionTrue
+			case apiextensionsv1.NamesAccepted:
+				if condition.Status == apiextensionsv1.ConditionFalse {
+					return false, fmt.Errorf("%s names not accepted: %s", name, condition.Message)
+				}
+				namesAccepted = condition.Status == apiextensionsv1.ConditionTrue
+			}
+		}
+		return established && namesAccepted, nil
+	})
+	if err == utilwait.ErrWaitTimeout || (err != nil && ctx.Err() == context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s: %w", name, wait.ErrTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Print("CRD已就绪: ", name)
+	return crd, nil
+}
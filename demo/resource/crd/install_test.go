@@ -0,0 +1,137 @@
+package crd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"resource-demo/wait"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 模拟apiextensions-apiserver：写入CRD时设置conditions
+func setConditions(client *fake.Clientset, namesAccepted apiextensionsv1.ConditionStatus) {
+	react := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		var obj runtime.Object
+		switch a := action.(type) {
+		case k8stesting.CreateAction:
+			obj = a.GetObject()
+		case k8stesting.UpdateAction:
+			obj = a.GetObject()
+		}
+		crd := obj.(*apiextensionsv1.CustomResourceDefinition)
+		crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{
+			{Type: apiextensionsv1.NamesAccepted, Status: namesAccepted, Message: "conflict"},
+			{Type: apiextensionsv1.Established, Status: namesAccepted},
+		}
+		// 交给后面的tracker保存
+		return false, nil, nil
+	}
+	client.PrependReactor("create", "customresourcedefinitions", react)
+	client.PrependReactor("update", "customresourcedefinitions", react)
+}
+
+func TestDecode(t *testing.T) {
+	definition, err := Decode(Definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if definition.Name != "redis.cs.handpay.cn" || definition.Spec.Names.Kind != "Redis" {
+		t.Errorf("unexpected definition %s %s", definition.Name, definition.Spec.Names.Kind)
+	}
+	// v1为存储版本，v2通过转换webhook提供
+	var versions []string
+	for _, version := range definition.Spec.Versions {
+		versions = append(versions, fmt.Sprintf("%s:%v:%v", version.Name, version.Served, version.Storage))
+	}
+	if got := strings.Join(versions, ","); got != "v1:true:true,v2:true:false" {
+		t.Errorf("versions = %s", got)
+	}
+	if err := SetCABundle(definition, []byte("ca")); err != nil {
+		t.Fatal(err)
+	}
+	if webhook := definition.Spec.Conversion.Webhook; webhook.ClientConfig.Service.Path == nil || *webhook.ClientConfig.Service.Path != "/convert" ||
+		string(webhook.ClientConfig.CABundle) != "ca" {
+		t.Errorf("unexpected conversion webhook %+v", webhook.ClientConfig)
+	}
+
+	if _, err := Decode([]byte("spec: {unknown: 1}")); err == nil {
+		t.Error("want error for unknown field")
+	}
+}
+
+func TestDisableConversion(t *testing.T) {
+	definition, err := Decode(Definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !UsesConversionWebhook(definition) {
+		t.Fatal("crd.yaml should use the conversion webhook")
+	}
+	DisableConversion(definition)
+	if UsesConversionWebhook(definition) || definition.Spec.Conversion.Strategy != apiextensionsv1.NoneConverter {
+		t.Errorf("conversion = %+v, want None", definition.Spec.Conversion)
+	}
+	// 只提供存储版本v1
+	var versions []string
+	for _, version := range definition.Spec.Versions {
+		versions = append(versions, fmt.Sprintf("%s:%v:%v", version.Name, version.Served, version.Storage))
+	}
+	if got := strings.Join(versions, ","); got != "v1:true:true,v2:false:false" {
+		t.Errorf("versions = %s", got)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	definition, err := Decode(Definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset()
+	setConditions(client, apiextensionsv1.ConditionTrue)
+
+	if _, err := Install(context.Background(), client, definition); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// 再次安装时更新已有CRD的spec
+	upgraded := definition.DeepCopy()
+	upgraded.Spec.Names.ShortNames = []string{"rd"}
+	installed, err := Install(context.Background(), client, upgraded)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := installed.Spec.Names.ShortNames; len(got) != 1 || got[0] != "rd" {
+		t.Errorf("shortNames = %v, want [rd]", got)
+	}
+}
+
+func TestInstallNotReady(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	definition, err := Decode(Definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewSimpleClientset()
+	setConditions(client, apiextensionsv1.ConditionFalse)
+	_, err = Install(context.Background(), client, definition)
+	if err == nil || !strings.Contains(err.Error(), "names not accepted") {
+		t.Errorf("names conflict: got %v", err)
+	}
+
+	// 没有conditions时一直等到超时
+	client = fake.NewSimpleClientset()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Install(ctx, client, definition); !errors.Is(err, wait.ErrTimeout) {
+		t.Errorf("not established: want ErrTimeout, got %v", err)
+	}
+}
@@ -3,31 +3,51 @@ package deployment
 import (
 	"context"
 	"fmt"
-	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"resource-demo/query"
 )
 
+// 默认的镜像和副本数，Create时未指定则使用
+const (
+	defaultImage    = "nginx:1.17"
+	defaultReplicas = 2
+)
+
+// 使用schema的包带入gvr
+var deploymentRes = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
 type Deployment struct {
-	Client dynamic.Interface
-	Name string
+	Client    dynamic.Interface
+	Name      string
 	Namespace string
+	// Update时要修改的镜像和副本数，为空则不修改；副本数可以为0
+	Image    string
+	Replicas *int32
 }
 
-func (d *Deployment) Create() {
+func (d *Deployment) resource() dynamic.ResourceInterface {
+	return d.Client.Resource(deploymentRes).Namespace(d.Namespace)
+}
 
+// 内置的deployment对象，create和apply使用
+func (d *Deployment) Object() *unstructured.Unstructured {
 	//定义函数内的变量
-	namespace := d.Namespace
-	replicas := 2
+	replicas := int64(defaultReplicas)
+	if d.Replicas != nil {
+		replicas = int64(*d.Replicas)
+	}
 	deployname := d.Name
-	image := "nginx:1.17"
+	image := defaultImage
+	if d.Image != "" {
+		image = d.Image
+	}
 
-	//使用schema的包带入gvr
-	deploymentRes := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
 	//定义结构化数据结构
-	deployment := &unstructured.Unstructured{
+	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "apps/v1",
 			"kind":       "Deployment",
@@ -49,15 +69,15 @@ func (d *Deployment) Create() {
 					},
 
 					"spec": map[string]interface{}{
-						"containers": []map[string]interface{}{
-							{
+						"containers": []interface{}{
+							map[string]interface{}{
 								"name":  "web",
 								"image": image,
-								"ports": []map[string]interface{}{
-									{
+								"ports": []interface{}{
+									map[string]interface{}{
 										"name":          "http",
 										"protocol":      "TCP",
-										"containerPort": 80,
+										"containerPort": int64(80),
 									},
 								},
 							},
@@ -67,28 +87,87 @@ func (d *Deployment) Create() {
 			},
 		},
 	}
+}
 
+func (d *Deployment) Create() (*unstructured.Unstructured, error) {
 	// 创建 Deployment
 	fmt.Println("创建 deployment...")
-	result, err := d.Client.Resource(deploymentRes).Namespace(namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+	result, err := d.resource().Create(context.TODO(), d.Object(), metav1.CreateOptions{})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	fmt.Printf("创建 deployment %q.\n", result.GetName())
+	return result, nil
+}
 
-	fmt.Printf("在命名空间中列出deployment %q:\n", apiv1.NamespaceDefault)
-	list, err := d.Client.Resource(deploymentRes).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		panic(err)
-	}
-	for _, d := range list.Items {
-		replicas, found, err := unstructured.NestedInt64(d.Object, "spec", "replicas")
-		if err != nil || !found {
-			fmt.Printf("Replicas not found for deployment %s: error=%s", d.GetName(), err)
-			continue
+// 查询单个deployment
+func (d *Deployment) Get() (*unstructured.Unstructured, error) {
+	return d.resource().Get(context.TODO(), d.Name, metav1.GetOptions{})
+}
+
+// 列出命名空间下的deployment
+func (d *Deployment) List(opts query.Options) (*unstructured.UnstructuredList, error) {
+	return query.ListAll(d.Client.Resource(deploymentRes).Namespace(opts.Namespace(d.Namespace)), opts)
+}
+
+// 更新镜像和副本数，冲突时重试
+func (d *Deployment) Update() (*unstructured.Unstructured, error) {
+	fmt.Println("更新 deployment...")
+	var updated *unstructured.Unstructured
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// 每次重试都取最新版本，避免覆盖别人的修改
+		result, getErr := d.resource().Get(context.TODO(), d.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return fmt.Errorf("failed to get latest version of Deployment: %w", getErr)
+		}
+
+		if d.Replicas != nil {
+			if err := unstructured.SetNestedField(result.Object, int64(*d.Replicas), "spec", "replicas"); err != nil {
+				return err
+			}
+		}
+
+		if d.Image != "" {
+			containers, found, err := unstructured.NestedSlice(result.Object, "spec", "template", "spec", "containers")
+			if err != nil || !found || len(containers) == 0 {
+				return fmt.Errorf("deployment containers not found or error in spec: %v", err)
+			}
+			// 只修改第一个容器的镜像
+			container, ok := containers[0].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("deployment containers[0] is %T, not an object", containers[0])
+			}
+			if err := unstructured.SetNestedField(container, d.Image, "image"); err != nil {
+				return err
+			}
+			if err := unstructured.SetNestedSlice(result.Object, containers, "spec", "template", "spec", "containers"); err != nil {
+				return err
+			}
 		}
-		fmt.Printf(" * %s (%d replicas)\n", d.GetName(), replicas)
+
+		var updateErr error
+		updated, updateErr = d.resource().Update(context.TODO(), result, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if retryErr != nil {
+		return nil, fmt.Errorf("update failed: %w", retryErr)
+	}
+
+	fmt.Printf("deployment %s 已更新\n", d.Name)
+	return updated, nil
+}
+
+func (d *Deployment) Delete() error {
+	fmt.Println("删除 deployment: " + d.Name)
+	deletePolicy := metav1.DeletePropagationForeground
+	err := d.resource().Delete(context.TODO(), d.Name, metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	})
+	if err != nil {
+		return err
 	}
 
+	fmt.Println("deployment: " + d.Name + "已删除")
+	return nil
 }
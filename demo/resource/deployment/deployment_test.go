@@ -0,0 +1,259 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+	"resource-demo/errs"
+	"resource-demo/query"
+	"testing"
+)
+
+func newFakeClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentRes: "DeploymentList"}, objects...)
+}
+
+// 取第一个容器的镜像
+func firstImage(obj *unstructured.Unstructured) string {
+	containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+	if len(containers) == 0 {
+		return ""
+	}
+	container, ok := containers[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	image, _, _ := unstructured.NestedString(container, "image")
+	return image
+}
+
+func TestDeploymentCRUD(t *testing.T) {
+	d := &Deployment{
+		Client:    newFakeClient(),
+		Name:      "demo",
+		Namespace: "default",
+	}
+
+	if _, err := d.Create(); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := d.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if image := firstImage(got); image != defaultImage {
+		t.Errorf("image = %q, want %q", image, defaultImage)
+	}
+
+	d.Image = "nginx:1.21"
+	d.Replicas = pointer.Int32(5)
+	if _, err := d.Update(); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err = d.Get()
+	if err != nil {
+		t.Fatalf("get after update: %v", err)
+	}
+	if image := firstImage(got); image != "nginx:1.21" {
+		t.Errorf("image = %q, want nginx:1.21", image)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(got.Object, "spec", "replicas"); replicas != 5 {
+		t.Errorf("replicas = %d, want 5", replicas)
+	}
+
+	list, err := d.List(query.Options{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("list returned %d items, want 1", len(list.Items))
+	}
+
+	if err := d.Delete(); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := d.Get(); !apierrors.IsNotFound(err) {
+		t.Errorf("get after delete: want NotFound, got %v", err)
+	}
+}
+
+func TestDeploymentUpdateRetriesOnConflict(t *testing.T) {
+	client := newFakeClient()
+	d := &Deployment{Client: client, Name: "demo", Namespace: "default"}
+	if _, err := d.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 前两次update返回冲突，第三次交给默认的tracker处理
+	conflicts := 0
+	client.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts < 2 {
+			conflicts++
+			return true, nil, apierrors.NewConflict(deploymentRes.GroupResource(), d.Name, fmt.Errorf("object was modified"))
+		}
+		return false, nil, nil
+	})
+
+	d.Replicas = pointer.Int32(3)
+	if _, err := d.Update(); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if conflicts != 2 {
+		t.Errorf("conflicts = %d, want 2", conflicts)
+	}
+
+	got, err := client.Resource(deploymentRes).Namespace("default").Get(context.TODO(), "demo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(got.Object, "spec", "replicas"); replicas != 3 {
+		t.Errorf("replicas = %d, want 3", replicas)
+	}
+}
+
+func TestDeploymentUpdateNotFound(t *testing.T) {
+	d := &Deployment{Client: newFakeClient(), Name: "missing", Namespace: "default", Replicas: pointer.Int32(1)}
+	if _, err := d.Update(); !apierrors.IsNotFound(err) {
+		t.Errorf("update of missing deployment: want NotFound, got %v", err)
+	}
+	if err := d.Delete(); !apierrors.IsNotFound(err) {
+		t.Errorf("delete of missing deployment: want NotFound, got %v", err)
+	}
+}
+
+func TestDeploymentErrors(t *testing.T) {
+	gr := deploymentRes.GroupResource()
+	tests := []struct {
+		name string
+		verb string
+		err  error
+		call func(d *Deployment) error
+		want errs.Reason
+	}{
+		{
+			name: "create exists",
+			verb: "create",
+			err:  apierrors.NewAlreadyExists(gr, "demo"),
+			call: func(d *Deployment) error { _, err := d.Create(); return err },
+			want: errs.AlreadyExists,
+		},
+		{
+			name: "create forbidden",
+			verb: "create",
+			err:  apierrors.NewForbidden(gr, "demo", fmt.Errorf("quota exceeded")),
+			call: func(d *Deployment) error { _, err := d.Create(); return err },
+			want: errs.Forbidden,
+		},
+		{
+			name: "update invalid",
+			verb: "update",
+			err:  apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "demo", nil),
+			call: func(d *Deployment) error { _, err := d.Update(); return err },
+			want: errs.Invalid,
+		},
+		{
+			name: "update keeps conflicting",
+			verb: "update",
+			err:  apierrors.NewConflict(gr, "demo", fmt.Errorf("object was modified")),
+			call: func(d *Deployment) error { _, err := d.Update(); return err },
+			want: errs.Conflict,
+		},
+		{
+			name: "get timeout",
+			verb: "get",
+			err:  apierrors.NewTimeoutError("request timed out", 1),
+			call: func(d *Deployment) error { _, err := d.Get(); return err },
+			want: errs.Timeout,
+		},
+		{
+			name: "delete forbidden",
+			verb: "delete",
+			err:  apierrors.NewForbidden(gr, "demo", fmt.Errorf("rbac")),
+			call: func(d *Deployment) error { return d.Delete() },
+			want: errs.Forbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			d := &Deployment{Client: client, Name: "demo", Namespace: "default", Replicas: pointer.Int32(1)}
+			if tt.verb != "create" {
+				if _, err := d.Create(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			client.PrependReactor(tt.verb, "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tt.err
+			})
+
+			err := tt.call(d)
+			if got := errs.Classify(err); got != tt.want {
+				t.Errorf("Classify(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+}
+
+// 可以缩容到0，不指定副本数时不修改
+func TestDeploymentScaleToZero(t *testing.T) {
+	client := newFakeClient()
+	d := &Deployment{Client: client, Name: "demo", Namespace: "default"}
+	if _, err := d.Create(); err != nil {
+		t.Fatal(err)
+	}
+	replicas := func() int64 {
+		got, err := d.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _, _ := unstructured.NestedInt64(got.Object, "spec", "replicas")
+		return n
+	}
+	if n := replicas(); n != defaultReplicas {
+		t.Errorf("replicas = %d, want %d", n, defaultReplicas)
+	}
+
+	d.Replicas = pointer.Int32(0)
+	if _, err := d.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if n := replicas(); n != 0 {
+		t.Errorf("replicas = %d, want 0", n)
+	}
+
+	d.Replicas = nil
+	d.Image = "nginx:1.21"
+	if _, err := d.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if n := replicas(); n != 0 {
+		t.Errorf("replicas = %d after updating the image, want 0", n)
+	}
+
+	zero := &Deployment{Name: "demo", Replicas: pointer.Int32(0)}
+	if n, _, _ := unstructured.NestedInt64(zero.Object().Object, "spec", "replicas"); n != 0 {
+		t.Errorf("Object() replicas = %d, want 0", n)
+	}
+}
+
+// containers中不是对象时返回错误，不能panic
+func TestDeploymentUpdateMalformedContainers(t *testing.T) {
+	malformed := (&Deployment{Name: "demo"}).Object()
+	malformed.SetNamespace("default")
+	if err := unstructured.SetNestedSlice(malformed.Object, []interface{}{"nginx"}, "spec", "template", "spec", "containers"); err != nil {
+		t.Fatal(err)
+	}
+	d := &Deployment{Client: newFakeClient(malformed), Name: "demo", Namespace: "default", Image: "nginx:1.21"}
+	if _, err := d.Update(); err == nil {
+		t.Error("want error for malformed containers")
+	}
+}
@@ -0,0 +1,60 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"resource-demo/wait"
+)
+
+// 错误的分类，根据apierrors判断，用于决定进程的退出码
+type Reason string
+
+const (
+	Unknown       Reason = "Unknown"
+	NotFound      Reason = "NotFound"
+	AlreadyExists Reason = "AlreadyExists"
+	Conflict      Reason = "Conflict"
+	Forbidden     Reason = "Forbidden"
+	Invalid       Reason = "Invalid"
+	Timeout       Reason = "Timeout"
+)
+
+// 每种分类对应的退出码，0表示成功，1为未分类的错误
+var exitCodes = map[Reason]int{
+	Unknown:       1,
+	Timeout:       2,
+	NotFound:      3,
+	AlreadyExists: 4,
+	Conflict:      5,
+	Forbidden:     6,
+	Invalid:       7,
+}
+
+// 对错误分类，err可以是被%w包装过的apierrors
+func Classify(err error) Reason {
+	switch {
+	case apierrors.IsNotFound(err):
+		return NotFound
+	case apierrors.IsAlreadyExists(err):
+		return AlreadyExists
+	case apierrors.IsConflict(err):
+		return Conflict
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return Forbidden
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return Invalid
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err),
+		errors.Is(err, wait.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	}
+	return Unknown
+}
+
+// err为nil时返回0
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return exitCodes[Classify(err)]
+}
@@ -0,0 +1,47 @@
+package errs
+
+import (
+	"context"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"resource-demo/wait"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	gr := schema.GroupResource{Resource: "pods"}
+	tests := []struct {
+		name string
+		err  error
+		want Reason
+		code int
+	}{
+		{name: "not found", err: apierrors.NewNotFound(gr, "demo"), want: NotFound, code: 3},
+		{name: "wrapped not found", err: fmt.Errorf("select resource ERROR: %w", apierrors.NewNotFound(gr, "demo")), want: NotFound, code: 3},
+		{name: "already exists", err: apierrors.NewAlreadyExists(gr, "demo"), want: AlreadyExists, code: 4},
+		{name: "conflict", err: apierrors.NewConflict(gr, "demo", fmt.Errorf("modified")), want: Conflict, code: 5},
+		{name: "forbidden", err: apierrors.NewForbidden(gr, "demo", fmt.Errorf("rbac")), want: Forbidden, code: 6},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("token expired"), want: Forbidden, code: 6},
+		{name: "invalid", err: apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "demo", nil), want: Invalid, code: 7},
+		{name: "bad request", err: apierrors.NewBadRequest("bad selector"), want: Invalid, code: 7},
+		{name: "server timeout", err: apierrors.NewServerTimeout(gr, "get", 1), want: Timeout, code: 2},
+		{name: "wait timeout", err: fmt.Errorf("demo: %w", wait.ErrTimeout), want: Timeout, code: 2},
+		{name: "deadline", err: context.DeadlineExceeded, want: Timeout, code: 2},
+		{name: "other", err: fmt.Errorf("boom"), want: Unknown, code: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify = %s, want %s", got, tt.want)
+			}
+			if got := ExitCode(tt.err); got != tt.code {
+				t.Errorf("ExitCode = %d, want %d", got, tt.code)
+			}
+		})
+	}
+
+	if code := ExitCode(nil); code != 0 {
+		t.Errorf("ExitCode(nil) = %d, want 0", code)
+	}
+}
@@ -0,0 +1,91 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"net/http"
+	v2 "resource-demo/apis/redis/v2"
+	"resource-demo/generated/clientset/versioned/scheme"
+
+	rest "k8s.io/client-go/rest"
+)
+
+type RedisV2Interface interface {
+	RESTClient() rest.Interface
+	RedisesGetter
+}
+
+// RedisV2Client is used to interact with features provided by the cs.handpay.cn group.
+type RedisV2Client struct {
+	restClient rest.Interface
+}
+
+func (c *RedisV2Client) Redises(namespace string) RedisInterface {
+	return newRedises(c, namespace)
+}
+
+// NewForConfig creates a new RedisV2Client for the given config.
+// NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
+// where httpClient was generated with rest.HTTPClientFor(c).
+func NewForConfig(c *rest.Config) (*RedisV2Client, error) {
+	config := *c
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, err
+	}
+	httpClient, err := rest.HTTPClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return NewForConfigAndClient(&config, httpClient)
+}
+
+// NewForConfigAndClient creates a new RedisV2Client for the given config and http client.
+// Note the http client provided takes precedence over the configured transport values.
+func NewForConfigAndClient(c *rest.Config, h *http.Client) (*RedisV2Client, error) {
+	config := *c
+	if err := setConfigDefaults(&config); err != nil {
+		return nil, err
+	}
+	client, err := rest.RESTClientForConfigAndClient(&config, h)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisV2Client{client}, nil
+}
+
+// NewForConfigOrDie creates a new RedisV2Client for the given config and
+// panics if there is an error in the config.
+func NewForConfigOrDie(c *rest.Config) *RedisV2Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New creates a new RedisV2Client for the given RESTClient.
+func New(c rest.Interface) *RedisV2Client {
+	return &RedisV2Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) error {
+	gv := v2.SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	return nil
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *RedisV2Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
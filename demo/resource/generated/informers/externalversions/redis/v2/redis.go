@@ -0,0 +1,74 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"context"
+	redisv2 "resource-demo/apis/redis/v2"
+	versioned "resource-demo/generated/clientset/versioned"
+	internalinterfaces "resource-demo/generated/informers/externalversions/internalinterfaces"
+	v2 "resource-demo/generated/listers/redis/v2"
+	time "time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// RedisInformer provides access to a shared informer and lister for
+// Redises.
+type RedisInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v2.RedisLister
+}
+
+type redisInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewRedisInformer constructs a new informer for Redis type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewRedisInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredRedisInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredRedisInformer constructs a new informer for Redis type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredRedisInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.RedisV2().Redises(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.RedisV2().Redises(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&redisv2.Redis{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *redisInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredRedisInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *redisInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&redisv2.Redis{}, f.defaultInformer)
+}
+
+func (f *redisInformer) Lister() v2.RedisLister {
+	return v2.NewRedisLister(f.Informer().GetIndexer())
+}
@@ -0,0 +1,11 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+// RedisListerExpansion allows custom methods to be added to
+// RedisLister.
+type RedisListerExpansion interface{}
+
+// RedisNamespaceListerExpansion allows custom methods to be added to
+// RedisNamespaceLister.
+type RedisNamespaceListerExpansion interface{}
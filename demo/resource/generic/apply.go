@@ -0,0 +1,171 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type ApplyOptions struct {
+	// 服务端记录字段归属时使用的manager名字
+	FieldManager string
+	// 和其他manager冲突时强制接管字段
+	Force bool
+}
+
+type ApplyResult struct {
+	Object  *unstructured.Unstructured
+	Created bool
+	// 发生变化的字段路径，例如 .spec.replicas
+	Changed []string
+}
+
+// 服务端apply时和其他field manager冲突的字段
+type Conflict struct {
+	Manager string `json:"manager"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ConflictError struct {
+	Kind      string     `json:"kind"`
+	Name      string     `json:"name"`
+	Conflicts []Conflict `json:"conflicts"`
+	err       error
+}
+
+func (e *ConflictError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.err
+}
+
+// 由服务端维护，比较变化时忽略
+var serverManagedFields = map[string]bool{
+	".metadata.resourceVersion":   true,
+	".metadata.managedFields":     true,
+	".metadata.generation":        true,
+	".metadata.creationTimestamp": true,
+	".metadata.uid":               true,
+	".metadata.selfLink":          true,
+	".status":                     true,
+}
+
+var conflictManager = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// 服务端apply：把Obj作为期望状态提交，由服务端合并并记录字段归属
+func (r *Resource) Apply(opts ApplyOptions) (*ApplyResult, error) {
+	if r.Obj == nil {
+		return nil, fmt.Errorf("%s 没有内置的资源对象，无法apply", r.kind())
+	}
+	name := r.Obj.GetName()
+
+	before, err := r.resource().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	// apply的对象里不能带resourceVersion和managedFields
+	obj := r.Obj.DeepCopy()
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := r.resource().Patch(context.TODO(), name, types.ApplyPatchType, data, patchOptions(opts))
+	if err != nil {
+		if apierrors.IsConflict(err) {
+			return nil, newConflictError(r.kind(), name, err)
+		}
+		return nil, err
+	}
+
+	result := &ApplyResult{Object: after, Created: before == nil}
+	if before != nil {
+		result.Changed = changedFields(before.Object, after.Object, "")
+	}
+
+	switch {
+	case result.Created:
+		fmt.Printf("%s: %s已创建\n", r.kind(), name)
+	case len(result.Changed) == 0:
+		fmt.Printf("%s: %s没有变化\n", r.kind(), name)
+	default:
+		fmt.Printf("%s: %s已配置，变化的字段:\n", r.kind(), name)
+		for _, field := range result.Changed {
+			fmt.Println("  " + field)
+		}
+	}
+	return result, nil
+}
+
+func patchOptions(opts ApplyOptions) metav1.PatchOptions {
+	force := opts.Force
+	return metav1.PatchOptions{
+		FieldManager: opts.FieldManager,
+		Force:        &force,
+	}
+}
+
+// 从Conflict状态的causes里取出冲突的字段和manager
+func newConflictError(kind, name string, err error) *ConflictError {
+	conflictErr := &ConflictError{Kind: kind, Name: name, err: err}
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			conflict := Conflict{Field: cause.Field, Message: cause.Message}
+			if m := conflictManager.FindStringSubmatch(cause.Message); m != nil {
+				conflict.Manager = m[1]
+			}
+			conflictErr.Conflicts = append(conflictErr.Conflicts, conflict)
+		}
+	}
+	return conflictErr
+}
+
+// 递归比较两个对象，返回不同的字段路径
+func changedFields(before, after interface{}, path string) []string {
+	if serverManagedFields[path] {
+		return nil
+	}
+
+	beforeMap, ok1 := before.(map[string]interface{})
+	afterMap, ok2 := after.(map[string]interface{})
+	if !ok1 || !ok2 {
+		if reflect.DeepEqual(before, after) {
+			return nil
+		}
+		return []string{path}
+	}
+
+	keys := map[string]bool{}
+	for k := range beforeMap {
+		keys[k] = true
+	}
+	for k := range afterMap {
+		keys[k] = true
+	}
+
+	var changed []string
+	for k := range keys {
+		field := path + "." + k
+		if strings.ContainsAny(k, ".[]") {
+			// label、annotation这类带点的key
+			field = fmt.Sprintf("%s[%q]", path, k)
+		}
+		changed = append(changed, changedFields(beforeMap[k], afterMap[k], field)...)
+	}
+	sort.Strings(changed)
+	return changed
+}
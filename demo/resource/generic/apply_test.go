@@ -0,0 +1,246 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"reflect"
+	"testing"
+)
+
+// 记录收到的apply patch，并像服务端一样把它写进tracker
+type patchRecorder struct {
+	client  *fakedynamic.FakeDynamicClient
+	patches []k8stesting.PatchActionImpl
+}
+
+func (p *patchRecorder) react(action k8stesting.Action) (bool, runtime.Object, error) {
+	patch := action.(k8stesting.PatchActionImpl)
+	p.patches = append(p.patches, patch)
+
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(patch.Patch, &obj.Object); err != nil {
+		return true, nil, err
+	}
+	tracker := p.client.Tracker()
+	existing, err := tracker.Get(patch.Resource, patch.Namespace, patch.Name)
+	if apierrors.IsNotFound(err) {
+		obj.SetResourceVersion("1")
+		return true, obj, tracker.Create(patch.Resource, obj, patch.Namespace)
+	}
+	if err != nil {
+		return true, nil, err
+	}
+	obj.SetResourceVersion(existing.(*unstructured.Unstructured).GetResourceVersion() + "1")
+	return true, obj, tracker.Update(patch.Resource, obj, patch.Namespace)
+}
+
+func redisObject(replicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cs.handpay.cn/v1",
+		"kind":       "Redis",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"command":  "echo redis crd2!",
+			"replicas": replicas,
+		},
+	}}
+}
+
+func newApplyResource(t *testing.T) (*Resource, *patchRecorder) {
+	mapping, err := ResolveKind(newMapper(t), "redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newApplyResourceFor(mapping)
+}
+
+func newApplyResourceFor(mapping *meta.RESTMapping) (*Resource, *patchRecorder) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{mapping.Resource: "RedisList"})
+	recorder := &patchRecorder{client: client}
+	client.PrependReactor("patch", "redis", recorder.react)
+	return &Resource{Client: client, Mapping: mapping, Name: "test", Namespace: "default"}, recorder
+}
+
+func TestApply(t *testing.T) {
+	r, recorder := newApplyResource(t)
+	opts := ApplyOptions{FieldManager: "resource-demo"}
+
+	r.Obj = redisObject(2)
+	result, err := r.Apply(opts)
+	if err != nil {
+		t.Fatalf("first apply: %v", err)
+	}
+	if !result.Created {
+		t.Error("first apply should create the object")
+	}
+
+	// 再次apply相同的对象不会报AlreadyExists
+	result, err = r.Apply(opts)
+	if err != nil {
+		t.Fatalf("second apply: %v", err)
+	}
+	if result.Created || len(result.Changed) != 0 {
+		t.Errorf("second apply: created=%v changed=%v, want no change", result.Created, result.Changed)
+	}
+
+	r.Obj = redisObject(5)
+	r.Obj.SetLabels(map[string]string{"app.kubernetes.io/name": "redis"})
+	result, err = r.Apply(opts)
+	if err != nil {
+		t.Fatalf("third apply: %v", err)
+	}
+	want := []string{`.metadata.labels`, `.spec.replicas`}
+	if !reflect.DeepEqual(result.Changed, want) {
+		t.Errorf("changed = %v, want %v", result.Changed, want)
+	}
+
+	if len(recorder.patches) != 3 {
+		t.Fatalf("recorded %d patches, want 3", len(recorder.patches))
+	}
+	for _, patch := range recorder.patches {
+		if patch.PatchType != types.ApplyPatchType {
+			t.Errorf("patch type = %v, want %v", patch.PatchType, types.ApplyPatchType)
+		}
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.patches[2].Patch, &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := unstructured.NestedString(body, "metadata", "resourceVersion"); found {
+		t.Error("apply patch must not carry a resourceVersion")
+	}
+}
+
+// CRD同时提供v2时，固定版本后apply的v1对象仍然发给v1的接口
+func TestApplyPinnedVersion(t *testing.T) {
+	redis := schema.GroupKind{Group: "cs.handpay.cn", Kind: "Redis"}
+	mapping, err := ResolveKindWithVersions(newMapperWithRedisV2(t), "redis", map[schema.GroupKind]string{redis: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, recorder := newApplyResourceFor(mapping)
+	r.Obj = redisObject(2)
+	if _, err := r.Apply(ApplyOptions{FieldManager: "resource-demo"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.patches) != 1 {
+		t.Fatalf("recorded %d patches, want 1", len(recorder.patches))
+	}
+	if gvr := recorder.patches[0].Resource; gvr.Version != "v1" {
+		t.Errorf("patched %v, want v1", gvr)
+	}
+}
+
+func TestApplyConflicts(t *testing.T) {
+	r, _ := newApplyResource(t)
+	r.Obj = redisObject(3)
+
+	status := apierrors.NewConflict(schema.GroupResource{Group: "cs.handpay.cn", Resource: "redis"}, "test", errors.New("Apply failed with 1 conflict"))
+	status.ErrStatus.Details.Causes = []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Message: `conflict with "kubectl-client-side-apply" using cs.handpay.cn/v1`,
+		Field:   ".spec.replicas",
+	}}
+	r.Client.(*fakedynamic.FakeDynamicClient).PrependReactor("patch", "redis", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, status
+	})
+
+	_, err := r.Apply(ApplyOptions{FieldManager: "resource-demo"})
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("want ConflictError, got %v", err)
+	}
+	if !apierrors.IsConflict(err) {
+		t.Error("ConflictError should still be recognised as a Conflict")
+	}
+	want := []Conflict{{Manager: "kubectl-client-side-apply", Field: ".spec.replicas", Message: status.ErrStatus.Details.Causes[0].Message}}
+	if !reflect.DeepEqual(conflictErr.Conflicts, want) {
+		t.Errorf("conflicts = %+v, want %+v", conflictErr.Conflicts, want)
+	}
+}
+
+// 假的dynamic client记录不到PatchOptions，包一层Client记录Apply实际传给服务端的参数
+type optionsRecorder struct {
+	dynamic.Interface
+	options []metav1.PatchOptions
+}
+
+func (o *optionsRecorder) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return optionsResource{o.Interface.Resource(gvr), o}
+}
+
+type optionsResource struct {
+	dynamic.NamespaceableResourceInterface
+	recorder *optionsRecorder
+}
+
+func (r optionsResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return optionsNamespacedResource{r.NamespaceableResourceInterface.Namespace(namespace), r.recorder}
+}
+
+type optionsNamespacedResource struct {
+	dynamic.ResourceInterface
+	recorder *optionsRecorder
+}
+
+func (r optionsNamespacedResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.recorder.options = append(r.recorder.options, options)
+	return r.ResourceInterface.Patch(ctx, name, pt, data, options, subresources...)
+}
+
+func TestApplyPatchOptions(t *testing.T) {
+	r, _ := newApplyResource(t)
+	recorder := &optionsRecorder{Interface: r.Client}
+	r.Client = recorder
+	r.Obj = redisObject(1)
+
+	for _, force := range []bool{false, true} {
+		if _, err := r.Apply(ApplyOptions{FieldManager: "resource-demo", Force: force}); err != nil {
+			t.Fatalf("apply force=%v: %v", force, err)
+		}
+	}
+	if len(recorder.options) != 2 {
+		t.Fatalf("recorded %d patches, want 2", len(recorder.options))
+	}
+	for i, force := range []bool{false, true} {
+		opts := recorder.options[i]
+		if opts.FieldManager != "resource-demo" || opts.Force == nil || *opts.Force != force {
+			t.Errorf("patch %d options = %+v, want fieldManager=resource-demo force=%v", i, opts, force)
+		}
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	before := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": "1",
+			"annotations":     map[string]interface{}{"cs.handpay.cn/owner": "a"},
+		},
+		"spec":   map[string]interface{}{"replicas": int64(1), "command": "echo"},
+		"status": map[string]interface{}{"phase": "Pending"},
+	}
+	after := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": "2",
+			"annotations":     map[string]interface{}{"cs.handpay.cn/owner": "b"},
+		},
+		"spec":   map[string]interface{}{"replicas": int64(1), "schedule": "2022-11-17T10:12:00Z"},
+		"status": map[string]interface{}{"phase": "Running"},
+	}
+	want := []string{`.metadata.annotations["cs.handpay.cn/owner"]`, ".spec.command", ".spec.schedule"}
+	if got := changedFields(before, after, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("changedFields = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package generic
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/retry"
+	"resource-demo/query"
+	"strings"
+)
+
+// 通过dynamic客户端操作任意资源
+type Resource struct {
+	Client    dynamic.Interface
+	Mapping   *meta.RESTMapping
+	Name      string
+	Namespace string
+	// create和update使用的资源对象
+	Obj *unstructured.Unstructured
+}
+
+// 基于discovery数据（即kubectl api-resources的结果）构建RESTMapper，支持简称
+func NewMapper(client discovery.DiscoveryInterface) meta.RESTMapper {
+	cached := memory.NewMemCacheClient(client)
+	return restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached)
+}
+
+// 把kind、简称或复数名（可带group，例如 redis.cs.handpay.cn）转成RESTMapping，忽略大小写
+func ResolveKind(mapper meta.RESTMapper, kind string) (*meta.RESTMapping, error) {
+	gr := schema.ParseGroupResource(strings.ToLower(kind))
+	gvr, err := mapper.ResourceFor(gr.WithVersion(""))
+	if err != nil {
+		return nil, fmt.Errorf("unknown kind %q: %v", kind, err)
+	}
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("unknown kind %q: %v", kind, err)
+	}
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// 同ResolveKind，kind在versions中时使用指定的版本，否则使用服务端的首选版本。
+// 例如CRD增加了v2后首选版本变成v2，而本地的对象还是v1
+func ResolveKindWithVersions(mapper meta.RESTMapper, kind string, versions map[schema.GroupKind]string) (*meta.RESTMapping, error) {
+	mapping, err := ResolveKind(mapper, kind)
+	if err != nil {
+		return nil, err
+	}
+	gk := mapping.GroupVersionKind.GroupKind()
+	if version, ok := versions[gk]; ok && version != mapping.GroupVersionKind.Version {
+		return mapper.RESTMapping(gk, version)
+	}
+	return mapping, nil
+}
+
+func (r *Resource) resource() dynamic.ResourceInterface {
+	if r.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return r.Client.Resource(r.Mapping.Resource).Namespace(r.Namespace)
+	}
+	return r.Client.Resource(r.Mapping.Resource)
+}
+
+func (r *Resource) kind() string {
+	return r.Mapping.GroupVersionKind.Kind
+}
+
+func (r *Resource) Create() (*unstructured.Unstructured, error) {
+	if r.Obj == nil {
+		return nil, fmt.Errorf("%s 没有内置的资源对象，无法create", r.kind())
+	}
+	fmt.Printf("创建%s: %s\n", r.kind(), r.Obj.GetName())
+	result, err := r.resource().Create(context.TODO(), r.Obj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("%s: %s已经创建\n", r.kind(), result.GetName())
+	return result, nil
+}
+
+// 用Obj覆盖已有资源，冲突时取最新版本重试
+func (r *Resource) Update() (*unstructured.Unstructured, error) {
+	if r.Obj == nil {
+		return nil, fmt.Errorf("%s 没有内置的资源对象，无法update", r.kind())
+	}
+	fmt.Printf("更新%s: %s\n", r.kind(), r.Obj.GetName())
+	var updated *unstructured.Unstructured
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := r.resource().Get(context.TODO(), r.Obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		obj := r.Obj.DeepCopy()
+		obj.SetResourceVersion(latest.GetResourceVersion())
+		updated, err = r.resource().Update(context.TODO(), obj, metav1.UpdateOptions{})
+		return err
+	})
+	if retryErr != nil {
+		return nil, fmt.Errorf("update failed: %w", retryErr)
+	}
+	fmt.Printf("%s: %s已更新\n", r.kind(), updated.GetName())
+	return updated, nil
+}
+
+func (r *Resource) Delete() error {
+	fmt.Printf("删除%s: %s\n", r.kind(), r.Name)
+	if err := r.resource().Delete(context.TODO(), r.Name, metav1.DeleteOptions{}); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s已删除\n", r.kind(), r.Name)
+	return nil
+}
+
+func (r *Resource) Get() (*unstructured.Unstructured, error) {
+	return r.resource().Get(context.TODO(), r.Name, metav1.GetOptions{})
+}
+
+func (r *Resource) List(opts query.Options) (*unstructured.UnstructuredList, error) {
+	if r.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return query.ListAll(r.Client.Resource(r.Mapping.Resource).Namespace(opts.Namespace(r.Namespace)), opts)
+	}
+	return query.ListAll(r.Client.Resource(r.Mapping.Resource), opts)
+}
@@ -0,0 +1,232 @@
+package generic
+
+import (
+	"bufio"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"os"
+	"resource-demo/query"
+	"strings"
+	"testing"
+)
+
+// 读取仓库根目录下 kubectl api-resources 的输出，转成discovery数据
+func loadAPIResources(t *testing.T) []*metav1.APIResourceList {
+	f, err := os.Open("../../../apiSource.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	header := scanner.Text()
+	column := func(line, name, next string) string {
+		start, end := strings.Index(header, name), strings.Index(header, next)
+		if next == "" || end > len(line) {
+			end = len(line)
+		}
+		return strings.TrimSpace(line[start:end])
+	}
+
+	lists := map[string]*metav1.APIResourceList{}
+	var order []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		groupVersion := column(line, "APIVERSION", "NAMESPACED")
+		resource := metav1.APIResource{
+			Name:       column(line, "NAME", "SHORTNAMES"),
+			Namespaced: column(line, "NAMESPACED", "KIND") == "true",
+			Kind:       column(line, "KIND", "VERBS"),
+			Verbs:      strings.Fields(strings.Trim(column(line, "VERBS", ""), "[]")),
+		}
+		if shortNames := column(line, "SHORTNAMES", "APIVERSION"); shortNames != "" {
+			resource.ShortNames = strings.Split(shortNames, ",")
+		}
+		if lists[groupVersion] == nil {
+			lists[groupVersion] = &metav1.APIResourceList{GroupVersion: groupVersion}
+			order = append(order, groupVersion)
+		}
+		lists[groupVersion].APIResources = append(lists[groupVersion].APIResources, resource)
+	}
+
+	result := make([]*metav1.APIResourceList, 0, len(order)+1)
+	for _, gv := range order {
+		result = append(result, lists[gv])
+	}
+	// crd.yaml 中定义的Redis
+	return append(result, &metav1.APIResourceList{
+		GroupVersion: "cs.handpay.cn/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "redis", SingularName: "star", ShortNames: []string{"st"}, Namespaced: true, Kind: "Redis"},
+		},
+	})
+}
+
+func newMapper(t *testing.T) meta.RESTMapper {
+	return NewMapper(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: loadAPIResources(t)}})
+}
+
+func TestResolveKind(t *testing.T) {
+	mapper := newMapper(t)
+	tests := []struct {
+		kind    string
+		want    schema.GroupVersionResource
+		scope   meta.RESTScopeName
+		wantErr bool
+	}{
+		{kind: "Pod", want: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "po", want: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "daemonSet", want: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "ds", want: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "job", want: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "DEPLOYMENTS", want: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "ns", want: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, scope: meta.RESTScopeNameRoot},
+		{kind: "crd", want: schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}, scope: meta.RESTScopeNameRoot},
+		{kind: "Redis", want: schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "redis.cs.handpay.cn", want: schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "st", want: schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}, scope: meta.RESTScopeNameNamespace},
+		{kind: "nosuchkind", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			mapping, err := ResolveKind(mapper, tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveKind(%q) error = %v, wantErr %v", tt.kind, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if mapping.Resource != tt.want {
+				t.Errorf("ResolveKind(%q) = %v, want %v", tt.kind, mapping.Resource, tt.want)
+			}
+			if mapping.Scope.Name() != tt.scope {
+				t.Errorf("ResolveKind(%q) scope = %v, want %v", tt.kind, mapping.Scope.Name(), tt.scope)
+			}
+		})
+	}
+}
+
+// 同时提供Redis的v1和v2，和apiserver一样把v2作为首选版本
+func newMapperWithRedisV2(t *testing.T) meta.RESTMapper {
+	resources := loadAPIResources(t)
+	v1 := resources[len(resources)-1]
+	v2 := *v1
+	v2.GroupVersion = "cs.handpay.cn/v2"
+	resources = append(resources[:len(resources)-1], &v2, v1)
+	return NewMapper(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: resources}})
+}
+
+func TestResolveKindWithVersions(t *testing.T) {
+	mapper := newMapperWithRedisV2(t)
+	redis := schema.GroupKind{Group: "cs.handpay.cn", Kind: "Redis"}
+
+	mapping, err := ResolveKind(mapper, "redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Resource.Version != "v2" {
+		t.Fatalf("ResolveKind(redis) = %v, want the preferred version v2", mapping.Resource)
+	}
+
+	for _, kind := range []string{"redis", "redis.cs.handpay.cn", "st"} {
+		mapping, err := ResolveKindWithVersions(mapper, kind, map[schema.GroupKind]string{redis: "v1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}
+		if mapping.Resource != want || mapping.GroupVersionKind.Version != "v1" {
+			t.Errorf("ResolveKindWithVersions(%q) = %v %v, want %v", kind, mapping.Resource, mapping.GroupVersionKind, want)
+		}
+	}
+
+	// 不在versions中的kind仍然使用首选版本
+	mapping, err = ResolveKindWithVersions(mapper, "deploy", map[schema.GroupKind]string{redis: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Resource.Version != "v1" || mapping.Resource.Group != "apps" {
+		t.Errorf("ResolveKindWithVersions(deploy) = %v", mapping.Resource)
+	}
+	if _, err := ResolveKindWithVersions(mapper, "redis", map[schema.GroupKind]string{redis: "v3"}); err == nil {
+		t.Error("want error for a version the server does not serve")
+	}
+}
+
+func TestResourceCRUD(t *testing.T) {
+	mapping, err := ResolveKind(newMapper(t), "cm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{mapping.Resource: "ConfigMapList"})
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "demo", "namespace": "default"},
+		"data":       map[string]interface{}{"key": "v1"},
+	}}
+	r := &Resource{Client: client, Mapping: mapping, Name: "demo", Namespace: "default", Obj: obj}
+
+	if _, err := r.Create(); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := r.Create(); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("second create: want AlreadyExists, got %v", err)
+	}
+
+	if err := unstructured.SetNestedField(obj.Object, "v2", "data", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Update(); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err := r.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if value, _, _ := unstructured.NestedString(got.Object, "data", "key"); value != "v2" {
+		t.Errorf("data.key = %q, want v2", value)
+	}
+
+	list, err := r.List(query.Options{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("list returned %d items, want 1", len(list.Items))
+	}
+
+	if err := r.Delete(); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := r.Get(); !apierrors.IsNotFound(err) {
+		t.Errorf("get after delete: want NotFound, got %v", err)
+	}
+}
+
+func TestResourceWithoutObject(t *testing.T) {
+	mapping, err := ResolveKind(newMapper(t), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Resource{Client: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme()), Mapping: mapping, Name: "demo"}
+	if _, err := r.Create(); err == nil {
+		t.Error("create without object: want error")
+	}
+	if _, err := r.Update(); err == nil {
+		t.Error("update without object: want error")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build tools
+// +build tools
+
+// 让go.mod记录代码生成器的版本，见 update-codegen.sh
+package tools
+
+import _ "k8s.io/code-generator"
@@ -0,0 +1,23 @@
+#!/usr/bin/env bash
+
+# 生成apis下的deepcopy，以及generated下的clientset、informers、listers
+# 用法：在demo/resource目录执行 ./hack/update-codegen.sh
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+CODEGEN_PKG=${CODEGEN_PKG:-$(cd "${SCRIPT_ROOT}" && go list -m -f '{{.Dir}}' k8s.io/code-generator)}
+
+# generate-groups.sh按GOPATH的目录结构输出，先生成到临时目录再拷贝回来
+OUTPUT_BASE=$(mktemp -d)
+trap 'rm -rf "${OUTPUT_BASE}"' EXIT
+
+bash "${CODEGEN_PKG}/generate-groups.sh" "deepcopy,client,informer,lister" \
+  resource-demo/generated resource-demo/apis \
+  redis:v1,v2 \
+  --output-base "${OUTPUT_BASE}" \
+  --go-header-file "${SCRIPT_ROOT}/hack/boilerplate.go.txt"
+
+cp -r "${OUTPUT_BASE}/resource-demo/." "${SCRIPT_ROOT}/"
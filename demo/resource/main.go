@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
@@ -17,17 +23,76 @@ import (
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
+	"os"
+	"os/signal"
 	"path/filepath"
+	redisv1 "resource-demo/apis/redis/v1"
 	"resource-demo/crd"
 	"resource-demo/deployment"
+	"resource-demo/errs"
+	"resource-demo/generated/clientset/versioned"
+	"resource-demo/generic"
+	"resource-demo/manifest"
 	"resource-demo/pod"
+	"resource-demo/printer"
+	"resource-demo/query"
+	"resource-demo/wait"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// 有内置资源对象的kind，其余kind走通用的dynamic处理
+var (
+	podKind        = schema.GroupKind{Kind: "Pod"}
+	deploymentKind = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	redisKind      = schema.GroupKind{Group: "cs.handpay.cn", Kind: "Redis"}
+)
+
+// 内置的Redis对象和类型化客户端都是v1，CRD同时提供v2时也使用v1，不依赖转换webhook
+var pinnedVersions = map[schema.GroupKind]string{
+	redisKind: redisv1.SchemeGroupVersion.Version,
+}
+
+// 兼容之前的 -kind=crd，表示示例中的Redis自定义资源
+var kindAliases = map[string]string{
+	"crd": "redis.cs.handpay.cn",
+}
+
+// 自定义数据
+const metaCRD = `
+apiVersion: "cs.handpay.cn/v1"
+kind: Redis
+metadata:
+  name: test
+  namespace: default
+spec:
+  schedule: "2022-11-17T10:12:00Z"
+  command: "echo redis crd2!"
+  replicas: 2
+  phase: "Running"
+`
+
 var kubeconfig *string
 var name string
 var namespace string
 var kind string
 var method string
+var image string
+var replicas int
+var labels string
+var annotations string
+var activeDeadlineSeconds int64
+var file string
+var fieldManager string
+var forceConflicts bool
+var output string
+var queryOptions query.Options
+var watchMode bool
+var waitMode bool
+var waitTimeout time.Duration
+var waitCondition string
+var caFile string
 
 func init() {
 	if home := homedir.HomeDir(); home != "" {
@@ -36,164 +101,645 @@ func init() {
 		kubeconfig = flag.String("kubeconfig", "", "kubeconfig file")
 	}
 
-	flag.StringVar(&method, "method", "create", "增删改查：create delete update search")
+	flag.StringVar(&method, "method", "create", "增删改查：create delete update search apply watch，install-crd安装或升级Redis的CRD")
 	flag.StringVar(&name, "name", "demo-pod", "资源名字")
-	flag.StringVar(&kind, "kind", "Pod", "资源类型，例如：pod、deployment、daemonSet、job、crd")
+	flag.StringVar(&kind, "kind", "Pod", "资源类型，支持kind、简称或复数名，忽略大小写，例如：pod、deployment、daemonSet、ds、job、crd")
 	flag.StringVar(&namespace, "namespace", "default", "命名空间")
+	flag.StringVar(&image, "image", "", "镜像，deployment修改第一个容器；pod格式为 容器名=镜像,容器名=镜像")
+	flag.IntVar(&replicas, "replicas", 0, "deployment的副本数，可以为0，update时不指定则不修改")
+	flag.StringVar(&labels, "labels", "", "pod update时要设置的label，格式 key=value,key=value")
+	flag.StringVar(&annotations, "annotations", "", "pod update时要设置的annotation，格式 key=value,key=value")
+	flag.StringVar(&file, "f", "", "资源清单：文件、目录或 -（标准输入），指定后忽略-kind和-name")
+	flag.StringVar(&queryOptions.LabelSelector, "l", "", "search时的label selector，例如 app=demo,tier!=db")
+	flag.StringVar(&queryOptions.LabelSelector, "selector", "", "同 -l")
+	flag.StringVar(&queryOptions.FieldSelector, "field-selector", "", "search时的field selector，例如 status.phase=Running")
+	flag.BoolVar(&queryOptions.AllNamespaces, "A", false, "search时列出所有命名空间的资源")
+	flag.BoolVar(&queryOptions.AllNamespaces, "all-namespaces", false, "同 -A")
+	flag.Int64Var(&queryOptions.ChunkSize, "chunk-size", query.DefaultChunkSize, "search时每次请求返回的最大条数，0表示不分页")
+	flag.BoolVar(&watchMode, "w", false, "search时先列出资源，再持续输出变化，同 -method=watch")
+	flag.BoolVar(&watchMode, "watch", false, "同 -w")
+	flag.StringVar(&output, "o", "", "search的输出格式：json|yaml|name|wide|custom-columns=HEADER:.path,...")
+	flag.StringVar(&fieldManager, "field-manager", "resource-demo", "apply时使用的field manager")
+	flag.BoolVar(&forceConflicts, "force-conflicts", false, "apply时强制接管其他field manager的字段")
+	flag.BoolVar(&waitMode, "wait", false, "create、update、apply后等待资源就绪，delete后等待资源被删除")
+	flag.DurationVar(&waitTimeout, "timeout", 60*time.Second, "-wait和install-crd等待就绪的超时时间，超时退出码为2")
+	flag.StringVar(&waitCondition, "for", "", "-wait的JSONPath条件，例如 {.spec.phase}=Running，不指定时pod等待Ready、deployment等待rollout完成、其他资源等待存在")
+	flag.StringVar(&caFile, "ca-file", "", "install-crd时转换webhook的CA证书，写入CRD的caBundle")
+	flag.Int64Var(&activeDeadlineSeconds, "active-deadline-seconds", 0, "pod update时设置activeDeadlineSeconds，0则不修改")
 }
 
 func main() {
 	flag.Parse()
+	exit(run())
+}
+
+// 出错时按错误的分类退出，见errs.ExitCode
+func exit(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Println(err)
+	os.Exit(errs.ExitCode(err))
+}
 
+func run() error {
 	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
-	switch kind {
-	case "pod":
-		client, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			panic(err)
-		}
-		newPod(client, method)
-		break
-	case "deployment":
-		// 使用clientSet也行，这里使用dynamic
-		client, err := dynamic.NewForConfig(config)
-		if err != nil {
-			panic(err)
-		}
-		newDeployment(client, method)
-		break
-	case "crd":
-		newCrd(config, method)
-	default:
-		break
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
 	}
-}
+	mapper := generic.NewMapper(discoveryClient)
 
-func newCrd(config *rest.Config, method string) {
-	// 创建dynamic客户端
 	dynamicClient, err := dynamic.NewForConfig(config)
-	// 创建discovery客户端
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
-		return
+		return err
 	}
 
-	// 自定义数据
-	var metaCRD = `
-apiVersion: "cs.handpay.cn/v1"
-kind: Redis
-metadata:
-  name: test
-  namespace: default
-spec:
-  schedule: "2022-11-17T10:12:00Z"
-  command: "echo redis crd2!"
-  replicas: 2
-  phase: "Running"
-`
+	if method == "install-crd" {
+		return installCRD(config, mapper)
+	}
 
-	obj := &unstructured.Unstructured{}
-	_, gvk, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode([]byte(metaCRD), nil, obj)
+	if file != "" {
+		return newManifests(dynamicClient, mapper, method)
+	}
 
-	// 获取GVK GVR 映射
-	mapperGVRGVK := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
-	// 根据资源GVK 获取资源的GVR GVK映射
-	resourceMapper, err := mapperGVRGVK.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if alias, ok := kindAliases[kind]; ok {
+		kind = alias
+	}
+	// 根据discovery数据把kind、简称或复数名转成GVR
+	mapping, err := generic.ResolveKindWithVersions(mapper, kind, pinnedVersions)
 	if err != nil {
-		return
+		if kind == kindAliases["crd"] {
+			return fmt.Errorf("%w，请先执行 -method=install-crd 安装Redis的CRD", err)
+		}
+		return err
 	}
 
-	var dr dynamic.ResourceInterface
-	if resourceMapper.Scope.Name() == meta.RESTScopeNameNamespace {
-		// 获取gvr对应的动态客户端
-		dr = dynamicClient.Resource(resourceMapper.Resource).Namespace(namespace)
-	} else {
-		// 获取gvr对应的动态客户端
-		dr = dynamicClient.Resource(resourceMapper.Resource)
+	if method == "watch" || (method == "search" && watchMode) {
+		return runWatch(dynamicClient, mapping)
 	}
 
+	if method == "apply" {
+		// apply统一走服务端apply，pod、deployment、Redis使用内置的资源对象
+		obj, err := builtinObject(mapping.GroupVersionKind.GroupKind())
+		if err != nil {
+			return err
+		}
+		resourceObject := generic.Resource{
+			Client:    dynamicClient,
+			Mapping:   mapping,
+			Name:      name,
+			Namespace: namespace,
+			Obj:       obj,
+		}
+		if err := runResource(&resourceObject, method, false); err != nil {
+			return err
+		}
+		return waitResource(dynamicClient, mapping, namespace, objectName(mapping.GroupVersionKind.GroupKind()), method)
+	}
+
+	switch mapping.GroupVersionKind.GroupKind() {
+	case podKind:
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return err
+		}
+		err = newPod(client, method)
+	case deploymentKind:
+		// 使用clientSet也行，这里使用dynamic
+		err = newDeployment(dynamicClient, method)
+	case redisKind:
+		err = newCrd(config, method)
+	default:
+		err = newResource(dynamicClient, mapping, method)
+	}
+	if err != nil {
+		return err
+	}
+
+	return waitResource(dynamicClient, mapping, namespace, objectName(mapping.GroupVersionKind.GroupKind()), method)
+}
+func newCrd(config *rest.Config, method string) error {
+	// 创建Redis的类型化客户端
+	client, err := versioned.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	obj, _, err := crdObject()
 	if err != nil {
-		panic(fmt.Errorf("failed to get dr: %v", err))
+		return err
+	}
+	redis, err := redisv1.FromUnstructured(obj)
+	if err != nil {
+		return err
 	}
 
 	crd := crd.Crd{
-		Dr:        dr,
-		Obj:       obj,
+		Client:    client,
+		Obj:       redis,
 		Name:      name,
 		Namespace: namespace,
 	}
-	if err != nil {
-		panic(fmt.Errorf("failed to get GVK: %v", err))
-	}
 
 	switch method {
 	case "create":
-		crd.Create()
-		break
+		_, err = crd.Create()
 	case "delete":
-		crd.Delete()
+		err = crd.Delete()
 	case "update":
 		// 更新
-		crd.Update()
-		break
+		_, err = crd.Update()
 	case "search":
-		crd.Get()
-		break
+		var items []redisv1.Redis
+		if nameSet() {
+			var redis *redisv1.Redis
+			if redis, err = crd.Get(); err != nil {
+				return err
+			}
+			items = append(items, *redis)
+		} else {
+			var list *redisv1.RedisList
+			if list, err = crd.List(queryOptions); err != nil {
+				return err
+			}
+			items = list.Items
+		}
+		return printRedis(config, items, !nameSet())
+	}
+	return err
+}
+
+// 表格列来自CRD的additionalPrinterColumns，需要dynamic客户端读取CRD
+func printRedis(config *rest.Config, items []redisv1.Redis, list bool) error {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	// 创建discovery客户端
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+	// 获取GVK GVR 映射
+	mapperGVRGVK := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	resourceMapper, err := mapperGVRGVK.RESTMapping(redisKind, redisv1.SchemeGroupVersion.Version)
+	if err != nil {
+		return err
+	}
+
+	objs := make([]unstructured.Unstructured, 0, len(items))
+	for i := range items {
+		obj, err := redisv1.ToUnstructured(&items[i])
+		if err != nil {
+			return err
+		}
+		objs = append(objs, *obj)
+	}
+	return printObjects(resourceColumns(dynamicClient, resourceMapper), list, objs...)
+}
+
+// 安装或升级Redis的CRD，等待就绪后重置mapper，使后续的操作能解析到Redis
+func installCRD(config *rest.Config, mapper meta.RESTMapper) error {
+	client, err := apiextensionsclientset.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	definition, err := crd.Decode(crd.Definition)
+	if err != nil {
+		return err
+	}
+	if caFile != "" {
+		caBundle, err := os.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		if err := crd.SetCABundle(definition, caBundle); err != nil {
+			return err
+		}
+	} else if crd.UsesConversionWebhook(definition) {
+		// 没有CA证书时apiserver调用不了转换webhook，读写v2都会失败
+		crd.DisableConversion(definition)
+		fmt.Println("没有指定-ca-file，不启用转换webhook，只提供存储版本")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	if _, err := crd.Install(ctx, client, definition); err != nil {
+		return err
+	}
+
+	// mapper缓存了安装前的discovery数据，不重置的话新的kind解析不到
+	meta.MaybeResetRESTMapper(mapper)
+	mapping, err := generic.ResolveKindWithVersions(mapper, kindAliases["crd"], pinnedVersions)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("%s 已可用\n", mapping.GroupVersionKind)
+	return nil
 }
 
-func newPod(client *kubernetes.Clientset, method string) {
+func newPod(client *kubernetes.Clientset, method string) error {
 	podObject := pod.Pod{
 		ClientSet: client,
 		PodName:   name,
 		Namespace: namespace,
 	}
 
+	var err error
 	switch method {
 	case "create":
-		podObject.Create()
-		break
+		_, err = podObject.Create()
 	case "delete":
-		podObject.Delete()
+		err = podObject.Delete()
 	case "update":
-		// TODO
-		break
+		if err = fillPodUpdate(&podObject); err != nil {
+			return err
+		}
+		_, err = podObject.Update()
 	case "search":
-		// 查询pod
-		//_, err = clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
-
+		err = searchPod(&podObject)
+	}
+	return err
+}
+func searchPod(podObject *pod.Pod) error {
+	gvk := corev1.SchemeGroupVersion.WithKind("Pod")
+	if !nameSet() {
 		// 查询podlist
-		podObject.GetList()
-		break
+		podList, err := podObject.GetList(queryOptions)
+		if err != nil {
+			return err
+		}
+		objs := make([]unstructured.Unstructured, 0, len(podList.Items))
+		for i := range podList.Items {
+			obj, err := printer.ToUnstructured(&podList.Items[i], gvk)
+			if err != nil {
+				return err
+			}
+			objs = append(objs, *obj)
+		}
+		return printObjects(printer.DefaultColumns(podKind), true, objs...)
+	}
+
+	// 指定了-name时查询单个pod，默认输出完整的状态
+	p, err := podObject.Get()
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		pod.Describe(os.Stdout, p)
+		return nil
+	}
+	obj, err := printer.ToUnstructured(p, gvk)
+	if err != nil {
+		return err
+	}
+	return printObjects(printer.DefaultColumns(podKind), false, *obj)
+}
+
+// 把命令行参数转成pod update要修改的字段
+func fillPodUpdate(p *pod.Pod) error {
+	var err error
+	if p.Labels, err = parseKeyValues(labels); err != nil {
+		return fmt.Errorf("invalid -labels: %v", err)
+	}
+	if p.Annotations, err = parseKeyValues(annotations); err != nil {
+		return fmt.Errorf("invalid -annotations: %v", err)
+	}
+	if p.Images, err = parseKeyValues(image); err != nil {
+		return fmt.Errorf("invalid -image: %v", err)
+	}
+	if activeDeadlineSeconds > 0 {
+		p.ActiveDeadlineSeconds = &activeDeadlineSeconds
+	}
+	return nil
+}
+
+// 解析 key=value,key=value 格式的参数
+func parseKeyValues(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	result := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%q is not in key=value format", pair)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
+}
+
+// 命令行是否显式指定了-name
+func nameSet() bool {
+	return flagSet("name")
+}
+
+// 命令行是否指定了参数
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// 指定了-replicas时返回副本数，可以为0；没有指定返回nil，不修改
+func replicasFlag() *int32 {
+	if !flagSet("replicas") {
+		return nil
 	}
+	r := int32(replicas)
+	return &r
 }
 
-func newDeployment(client dynamic.Interface, method string) {
+func newDeployment(client dynamic.Interface, method string) error {
 	deploymentObject := deployment.Deployment{
 		Client:    client,
 		Name:      name,
 		Namespace: namespace,
+		Image:     image,
+		Replicas:  replicasFlag(),
+	}
+
+	var err error
+	switch method {
+	case "create":
+		_, err = deploymentObject.Create()
+	case "delete":
+		err = deploymentObject.Delete()
+	case "update":
+		_, err = deploymentObject.Update()
+	case "search":
+		if nameSet() {
+			// 查询单个deployment
+			var obj *unstructured.Unstructured
+			if obj, err = deploymentObject.Get(); err != nil {
+				return err
+			}
+			return printObjects(printer.DefaultColumns(deploymentKind), false, *obj)
+		}
+		// 查询deployment列表
+		var list *unstructured.UnstructuredList
+		if list, err = deploymentObject.List(queryOptions); err != nil {
+			return err
+		}
+		return printObjects(printer.DefaultColumns(deploymentKind), true, list.Items...)
+	}
+	return err
+}
+func newResource(client dynamic.Interface, mapping *meta.RESTMapping, method string) error {
+	resourceObject := generic.Resource{
+		Client:    client,
+		Mapping:   mapping,
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	return runResource(&resourceObject, method, !nameSet())
+}
+
+// 对-f读取到的每个对象，按它自己的GVK执行操作
+// 某个对象失败时继续处理其余对象，最后返回的错误包装了最后一个失败对象的错误
+func newManifests(client dynamic.Interface, mapper meta.RESTMapper, method string) error {
+	objects, err := manifest.Load(file, os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	failed := 0
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			fmt.Println(err)
+			lastErr, failed = err, failed+1
+			continue
+		}
+
+		ns := ""
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			// 清单里没写namespace时使用-namespace
+			ns = obj.GetNamespace()
+			if ns == "" {
+				ns = namespace
+				obj.SetNamespace(ns)
+			}
+		}
+
+		resourceObject := generic.Resource{
+			Client:    client,
+			Mapping:   mapping,
+			Name:      obj.GetName(),
+			Namespace: ns,
+			Obj:       obj,
+		}
+		if err := runResource(&resourceObject, method, false); err != nil {
+			fmt.Println(err)
+			lastErr, failed = err, failed+1
+			continue
+		}
+		if err := waitResource(client, mapping, ns, obj.GetName(), method); err != nil {
+			fmt.Println(err)
+			lastErr, failed = err, failed+1
+		}
 	}
+	if lastErr != nil {
+		return fmt.Errorf("%d/%d objects failed, last error: %w", failed, len(objects), lastErr)
+	}
+	return nil
+}
 
+// list为true时search列出所有资源，否则只查询Name指定的资源
+func runResource(r *generic.Resource, method string, list bool) error {
+	var err error
 	switch method {
 	case "create":
-		deploymentObject.Create()
+		_, err = r.Create()
 		break
 	case "delete":
-		// TODO
+		err = r.Delete()
 		break
 	case "update":
-		// TODO
+		_, err = r.Update()
 		break
 	case "search":
-		// TODO
+		columns := resourceColumns(r.Client, r.Mapping)
+		if list {
+			var result *unstructured.UnstructuredList
+			if result, err = r.List(queryOptions); err == nil {
+				err = printObjects(columns, true, result.Items...)
+			}
+			break
+		}
+		var obj *unstructured.Unstructured
+		if obj, err = r.Get(); err == nil {
+			err = printObjects(columns, false, *obj)
+		}
 		break
+	case "apply":
+		_, err = r.Apply(generic.ApplyOptions{FieldManager: fieldManager, Force: forceConflicts})
+		var conflictErr *generic.ConflictError
+		if errors.As(err, &conflictErr) {
+			// 冲突以JSON输出，方便脚本处理
+			data, _ := json.MarshalIndent(conflictErr, "", "  ")
+			fmt.Println(string(data))
+		}
+		break
+	}
+	return err
+}
+
+// 对任意kind先list再watch，按-o输出事件，Ctrl+C时退出
+func runWatch(client dynamic.Interface, mapping *meta.RESTMapping) error {
+	var resourceClient dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resourceClient = client.Resource(mapping.Resource).Namespace(queryOptions.Namespace(namespace))
+	}
+
+	opts := queryOptions
+	if nameSet() {
+		// 只watch -name指定的资源
+		selector := "metadata.name=" + name
+		if opts.FieldSelector != "" {
+			selector = opts.FieldSelector + "," + selector
+		}
+		opts.FieldSelector = selector
+	}
+
+	p, err := printer.NewEventPrinter(output, resourceColumns(client, mapping), queryOptions.AllNamespaces)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return query.Watch(ctx, resourceClient, opts, func(event watch.Event) error {
+		return p.PrintEvent(os.Stdout, string(event.Type), event.Object.(*unstructured.Unstructured))
+	})
+}
+
+func waitable(method string) bool {
+	return method == "create" || method == "update" || method == "apply" || method == "delete"
+}
+
+// 指定了-wait时等待资源满足条件：delete后等待删除，否则等待-for或按kind的默认条件
+func waitResource(client dynamic.Interface, mapping *meta.RESTMapping, ns, objName, method string) error {
+	if !waitMode || !waitable(method) {
+		return nil
+	}
+	cond, err := resourceCondition(mapping.GroupVersionKind.GroupKind(), method)
+	if err != nil {
+		return err
+	}
+	var resourceClient dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resourceClient = client.Resource(mapping.Resource).Namespace(ns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	fmt.Printf("等待%s: %s\n", mapping.GroupVersionKind.Kind, objName)
+	if err := wait.For(ctx, resourceClient, objName, cond); err != nil {
+		return err
 	}
+	fmt.Printf("%s: %s 已满足条件\n", mapping.GroupVersionKind.Kind, objName)
+	return nil
+}
+
+func resourceCondition(gk schema.GroupKind, method string) (wait.Condition, error) {
+	if method == "delete" {
+		return wait.Deleted, nil
+	}
+	if waitCondition != "" {
+		return wait.JSONPath(waitCondition)
+	}
+	switch gk {
+	case podKind:
+		return wait.PodReady, nil
+	case deploymentKind:
+		return wait.DeploymentComplete, nil
+	}
+	return wait.Exists, nil
+}
+
+// 内置资源对象的名字，Redis没有指定-name时使用metaCRD中的名字
+func objectName(gk schema.GroupKind) string {
+	if gk == redisKind && !nameSet() {
+		if obj, _, err := crdObject(); err == nil {
+			return obj.GetName()
+		}
+	}
+	return name
+}
+
+// 解析metaCRD，指定了-name时替换名字
+func crdObject() (*unstructured.Unstructured, *schema.GroupVersionKind, error) {
+	obj := &unstructured.Unstructured{}
+	_, gvk, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode([]byte(metaCRD), nil, obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	if nameSet() {
+		obj.SetName(name)
+	}
+	return obj, gvk, nil
+}
+
+// 表格的默认列：内置kind使用固定的列，自定义资源使用CRD的additionalPrinterColumns
+func resourceColumns(client dynamic.Interface, mapping *meta.RESTMapping) []printer.Column {
+	gk := mapping.GroupVersionKind.GroupKind()
+	if gk == podKind || gk == deploymentKind {
+		return printer.DefaultColumns(gk)
+	}
+	if columns, err := printer.CRDColumns(client, mapping.Resource); err == nil && len(columns) > 0 {
+		return columns
+	}
+	return printer.DefaultColumns(gk)
+}
 
+// 按-o指定的格式输出查询结果，list表示结果来自list请求而不是按名字查询
+func printObjects(columns []printer.Column, list bool, objs ...unstructured.Unstructured) error {
+	p, err := printer.New(output, columns, queryOptions.AllNamespaces)
+	if err != nil {
+		return err
+	}
+	return p.Print(os.Stdout, objs, list)
+}
+
+// pod、deployment、Redis内置的资源对象，其余kind没有
+func builtinObject(gk schema.GroupKind) (*unstructured.Unstructured, error) {
+	switch gk {
+	case podKind:
+		podObject := pod.Pod{PodName: name, Namespace: namespace}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(podObject.Object())
+		if err != nil {
+			return nil, err
+		}
+		return &unstructured.Unstructured{Object: content}, nil
+	case deploymentKind:
+		deploymentObject := deployment.Deployment{Name: name, Namespace: namespace, Image: image, Replicas: replicasFlag()}
+		obj := deploymentObject.Object()
+		obj.SetNamespace(namespace)
+		return obj, nil
+	case redisKind:
+		// 和create、-wait一样，没有指定-name时使用metaCRD中的名字
+		obj, _, err := crdObject()
+		if err != nil {
+			return nil, err
+		}
+		obj.SetNamespace(namespace)
+		return obj, nil
+	}
+	return nil, nil
 }
 
 // 新建namespace
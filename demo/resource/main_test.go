@@ -1,16 +1,50 @@
 package main
 
 import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"resource-demo/generic"
 	"testing"
-	"time"
 )
 
+// 这些测试需要真实集群，没有kubeconfig时跳过
+func loadConfig(t *testing.T) *rest.Config {
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		t.Skipf("no cluster available: %v", err)
+	}
+	return config
+}
+
+// 等待上一步操作生效：create、update后资源就绪，delete后资源被删除
+func waitDone(t *testing.T, config *rest.Config, gk schema.GroupKind, method string) {
+	t.Helper()
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := generic.NewMapper(discoveryClient).RESTMapping(gk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitMode = true
+	defer func() { waitMode = false }()
+	if err := waitResource(client, mapping, namespace, objectName(gk), method); err != nil {
+		t.Errorf("%s: %v", method, err)
+	}
+}
+
 func TestPod(t *testing.T)  {
-	config, _ := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config := loadConfig(t)
 	client, _ := kubernetes.NewForConfig(config)
 
 	test := []struct{
@@ -24,13 +58,16 @@ func TestPod(t *testing.T)  {
 	}
 
 	for _,tt := range test{
-		newPod(tt.client, tt.method)
-		time.Sleep(time.Second)
+		if err := newPod(tt.client, tt.method); err != nil {
+			t.Errorf("%s: %v", tt.method, err)
+			continue
+		}
+		waitDone(t, config, podKind, tt.method)
 	}
 }
 
 func TestDeployment(t *testing.T)  {
-	config, _ := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config := loadConfig(t)
 	client, _ := dynamic.NewForConfig(config)
 
 	test := []struct{
@@ -44,13 +81,16 @@ func TestDeployment(t *testing.T)  {
 	}
 
 	for _,tt := range test{
-		newDeployment(tt.client, tt.method)
-		time.Sleep(time.Second)
+		if err := newDeployment(tt.client, tt.method); err != nil {
+			t.Errorf("%s: %v", tt.method, err)
+			continue
+		}
+		waitDone(t, config, deploymentKind, tt.method)
 	}
 }
 
 func TestCrd(t *testing.T)  {
-	config, _ := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config := loadConfig(t)
 
 	test := []struct{
 		config *rest.Config
@@ -63,7 +103,10 @@ func TestCrd(t *testing.T)  {
 	}
 
 	for _,tt := range test{
-		newCrd(tt.config, tt.method)
-		time.Sleep(time.Second)
+		if err := newCrd(tt.config, tt.method); err != nil {
+			t.Errorf("%s: %v", tt.method, err)
+			continue
+		}
+		waitDone(t, config, redisKind, tt.method)
 	}
 }
\ No newline at end of file
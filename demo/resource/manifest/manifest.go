@@ -0,0 +1,167 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 目录中会读取的文件后缀
+var extensions = []string{".yaml", ".yml", ".json"}
+
+var decoder = yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+
+// 读取文件、目录（不递归）或 "-"（标准输入）中的资源清单
+func Load(path string, stdin io.Reader) ([]*unstructured.Unstructured, error) {
+	if path == "-" {
+		return Decode(stdin)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadFile(path)
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	// 按文件名排序，保证crd.yaml这类定义先于使用它的资源
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var objects []*unstructured.Unstructured
+	for _, entry := range entries {
+		if entry.IsDir() || !hasManifestExtension(entry.Name()) {
+			continue
+		}
+		objs, err := loadFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, objs...)
+	}
+	return objects, nil
+}
+
+func loadFile(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	objects, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return objects, nil
+}
+
+// 把多文档的YAML或JSON拆开，逐个解码成unstructured对象，List会展开成其中的items
+func Decode(r io.Reader) ([]*unstructured.Unstructured, error) {
+	docs, err := split(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	for i, doc := range docs {
+		data, err := utilyaml.ToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+		// 只有注释或者空白的文档
+		if len(bytes.TrimSpace(data)) == 0 || string(data) == "null" {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(data, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+		switch o := obj.(type) {
+		case *unstructured.Unstructured:
+			objects = append(objects, o)
+		case *unstructured.UnstructuredList:
+			err = o.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("document %d: unexpected type %T", i, obj)
+		}
+	}
+	return objects, nil
+}
+
+// JSON流按对象拆分，其余按YAML的 "---" 拆分
+func split(r *bufio.Reader) ([][]byte, error) {
+	if isJSON(r) {
+		var docs [][]byte
+		d := json.NewDecoder(r)
+		for {
+			var doc json.RawMessage
+			if err := d.Decode(&doc); err == io.EOF {
+				return docs, nil
+			} else if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(r)
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// 跳过开头的空白，看第一个字符是不是 {
+func isJSON(r *bufio.Reader) bool {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.ReadByte()
+		default:
+			return b[0] == '{'
+		}
+	}
+}
+
+func hasManifestExtension(name string) bool {
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
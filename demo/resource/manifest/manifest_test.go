@@ -0,0 +1,126 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func kinds(t *testing.T, path string, stdin string) []string {
+	objects, err := Load(path, strings.NewReader(stdin))
+	if err != nil {
+		t.Fatalf("Load(%q): %v", path, err)
+	}
+	result := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		result = append(result, obj.GetKind()+"/"+obj.GetName())
+	}
+	return result
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		stdin string
+		want  []string
+	}{
+		{
+			name: "crd directory",
+			path: "../crd/yml",
+			want: []string{"CustomResourceDefinition/redis.cs.handpay.cn", "Redis/example-redis"},
+		},
+		{
+			name: "multi document with leading comments",
+			path: "../../../kubectl/yaml/example2.yaml",
+			want: []string{"Deployment/hello-world-app1", "Ingress/example-ingress", "Service/hello-world-app"},
+		},
+		{
+			name: "pv directory",
+			path: "../../../kubectl/yaml/pv",
+			want: []string{"PersistentVolume/cloud", "PersistentVolumeClaim/cloud"},
+		},
+		{
+			name: "yaml from stdin",
+			path: "-",
+			stdin: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+# only a comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`,
+			want: []string{"ConfigMap/a", "ConfigMap/b"},
+		},
+		{
+			name: "json stream from stdin",
+			path: "-",
+			stdin: `
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}}
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}`,
+			want: []string{"ConfigMap/a", "Secret/b"},
+		},
+		{
+			name: "list is expanded",
+			path: "-",
+			stdin: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: a
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: b
+`,
+			want: []string{"ConfigMap/a", "Deployment/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := kinds(t, tt.path, tt.stdin)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKubectlManifests(t *testing.T) {
+	objects, err := Load("../../../kubectl/yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// kubectl/yaml 下的每个清单都能直接使用，pv子目录不会被读取
+	for _, obj := range objects {
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+			t.Errorf("incomplete object: %v", obj.Object)
+		}
+	}
+	if len(objects) != 11 {
+		t.Errorf("loaded %d objects, want 11", len(objects))
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing kind":  "apiVersion: v1\nmetadata:\n  name: a\n",
+		"invalid yaml":  "kind: [\n",
+		"invalid json":  `{"apiVersion": "v1",`,
+		"not an object": "- a\n- b\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(input)); err == nil {
+				t.Error("want error, got nil")
+			}
+		})
+	}
+}
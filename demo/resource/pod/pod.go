@@ -3,50 +3,152 @@ package pod
 import (
 	"context"
 	"fmt"
+	"io"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"resource-demo/query"
+	"sort"
+	"strings"
 )
 
 type Pod struct {
-	ClientSet *kubernetes.Clientset
-	PodName string
+	ClientSet kubernetes.Interface
+	PodName   string
 	Namespace string
+
+	// Update时要修改的字段，运行中的Pod只允许改这些
+	Labels      map[string]string
+	Annotations map[string]string
+	// 容器名 -> 镜像
+	Images                map[string]string
+	ActiveDeadlineSeconds *int64
 }
 
 var name = "zhang"
 
-func (p *Pod) Delete(){
+func (p *Pod) Delete() error {
 	fmt.Println("删除pod: " + p.PodName)
 	err := p.ClientSet.CoreV1().Pods(p.Namespace).Delete(context.TODO(), p.PodName, metav1.DeleteOptions{})
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
 	fmt.Println("pod: " + p.PodName + "已删除")
+	return nil
 }
 
-func (p *Pod) GetList() {
-	fmt.Println("查询pod列表")
-	podList, err  := p.ClientSet.CoreV1().Pods(p.Namespace).List(context.TODO(), metav1.ListOptions{})
+// 查询pod列表，按selector过滤并分页取完，输出交给printer
+func (p *Pod) GetList(opts query.Options) (*corev1.PodList, error) {
+	result := &corev1.PodList{}
+	err := query.Paginate(opts.ListOptions(), func(listOptions metav1.ListOptions) (string, error) {
+		podList, err := p.ClientSet.CoreV1().Pods(opts.Namespace(p.Namespace)).List(context.TODO(), listOptions)
+		if err != nil {
+			return "", err
+		}
+		result.ResourceVersion = podList.ResourceVersion
+		result.Items = append(result.Items, podList.Items...)
+		return podList.Continue, nil
+	})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	fmt.Printf("%v\t %v\t %v\n", "namespace", "status", "name")
-	for _, pod := range podList.Items {
-		fmt.Printf("%v\t %v\t %v\n",
-			pod.Namespace,
-			pod.Status.Phase,
-			pod.Name)
+	return result, nil
+}
+
+// 查询单个pod
+func (p *Pod) Get() (*corev1.Pod, error) {
+	return p.ClientSet.CoreV1().Pods(p.Namespace).Get(context.TODO(), p.PodName, metav1.GetOptions{})
+}
+
+// 打印pod完整的状态：conditions、容器状态、重启次数、节点和IP
+func Describe(w io.Writer, pod *corev1.Pod) {
+	fmt.Fprintf(w, "Name:\t\t%s\n", pod.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", pod.Namespace)
+	fmt.Fprintln(w, "Labels:")
+	for _, k := range sortedKeys(pod.Labels) {
+		fmt.Fprintf(w, "  %s=%s\n", k, pod.Labels[k])
+	}
+	fmt.Fprintf(w, "Node:\t\t%s\n", pod.Spec.NodeName)
+	fmt.Fprintf(w, "Status:\t\t%s\n", pod.Status.Phase)
+	fmt.Fprintf(w, "Host IP:\t%s\n", pod.Status.HostIP)
+	fmt.Fprintf(w, "Pod IPs:\t%s\n", podIPs(pod))
+
+	fmt.Fprintln(w, "Conditions:")
+	fmt.Fprintf(w, "  %-16s %-8s %s\n", "Type", "Status", "Reason")
+	for _, c := range pod.Status.Conditions {
+		fmt.Fprintf(w, "  %-16s %-8s %s\n", c.Type, c.Status, c.Reason)
+	}
+
+	fmt.Fprintln(w, "Containers:")
+	for _, cs := range pod.Status.ContainerStatuses {
+		fmt.Fprintf(w, "  %s:\n", cs.Name)
+		fmt.Fprintf(w, "    Image:\t%s\n", cs.Image)
+		fmt.Fprintf(w, "    State:\t%s\n", containerState(cs.State))
+		if cs.LastTerminationState.Terminated != nil {
+			fmt.Fprintf(w, "    Last State:\t%s\n", containerState(cs.LastTerminationState))
+		}
+		fmt.Fprintf(w, "    Ready:\t%t\n", cs.Ready)
+		fmt.Fprintf(w, "    Restarts:\t%d\n", cs.RestartCount)
 	}
 }
 
-func (p *Pod) Create(){
-	fmt.Println("创建pod: " + p.PodName)
-	// 创建pod
-	newPod := corev1.Pod{
+// 更新运行中pod允许修改的字段：labels、annotations、容器镜像、activeDeadlineSeconds
+func (p *Pod) Update() (*corev1.Pod, error) {
+	fmt.Println("更新pod: " + p.PodName)
+	var updated *corev1.Pod
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// 每次重试都取最新版本
+		pod, err := p.ClientSet.CoreV1().Pods(p.Namespace).Get(context.TODO(), p.PodName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if len(p.Labels) > 0 && pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		for k, v := range p.Labels {
+			pod.Labels[k] = v
+		}
+		if len(p.Annotations) > 0 && pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		for k, v := range p.Annotations {
+			pod.Annotations[k] = v
+		}
+
+		for container, image := range p.Images {
+			if !setImage(pod.Spec.Containers, container, image) && !setImage(pod.Spec.InitContainers, container, image) {
+				return fmt.Errorf("container %q not found in pod %s", container, p.PodName)
+			}
+		}
+
+		if p.ActiveDeadlineSeconds != nil {
+			pod.Spec.ActiveDeadlineSeconds = p.ActiveDeadlineSeconds
+		}
+
+		updated, err = p.ClientSet.CoreV1().Pods(p.Namespace).Update(context.TODO(), pod, metav1.UpdateOptions{})
+		return err
+	})
+	if retryErr != nil {
+		return nil, fmt.Errorf("update failed: %w", retryErr)
+	}
+
+	fmt.Println("pod: " + p.PodName + "已更新")
+	return updated, nil
+}
+
+// 内置的pod对象，create和apply使用
+func (p *Pod) Object() *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: p.PodName,
+			Name:      p.PodName,
+			Namespace: p.Namespace,
 		},
 		Spec: corev1.PodSpec{
 			Containers: []corev1.Container{
@@ -54,11 +156,59 @@ func (p *Pod) Create(){
 			},
 		},
 	}
+}
 
-	obj, err := p.ClientSet.CoreV1().Pods(p.Namespace).Create(context.Background(), &newPod, metav1.CreateOptions{})
+func (p *Pod) Create() (*corev1.Pod, error) {
+	fmt.Println("创建pod: " + p.PodName)
+	// 创建pod
+	obj, err := p.ClientSet.CoreV1().Pods(p.Namespace).Create(context.Background(), p.Object(), metav1.CreateOptions{})
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
 	}
 
 	fmt.Println("pod: " + obj.GetName() + "已经创建")
+	return obj, nil
+}
+
+func setImage(containers []corev1.Container, name, image string) bool {
+	for i := range containers {
+		if containers[i].Name == name {
+			containers[i].Image = image
+			return true
+		}
+	}
+	return false
+}
+
+func podIPs(pod *corev1.Pod) string {
+	if len(pod.Status.PodIPs) == 0 {
+		return pod.Status.PodIP
+	}
+	ips := make([]string, 0, len(pod.Status.PodIPs))
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	return strings.Join(ips, ",")
+}
+
+func containerState(state corev1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return fmt.Sprintf("Running (started %s)", state.Running.StartedAt.Format("2006-01-02 15:04:05"))
+	case state.Waiting != nil:
+		return fmt.Sprintf("Waiting (%s)", state.Waiting.Reason)
+	case state.Terminated != nil:
+		return fmt.Sprintf("Terminated (%s, exit code %d)", state.Terminated.Reason, state.Terminated.ExitCode)
+	}
+	return "Unknown"
+}
+
+// 按key排序输出，方便阅读
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
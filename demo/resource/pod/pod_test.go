@@ -0,0 +1,273 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"resource-demo/errs"
+	"resource-demo/query"
+	"testing"
+)
+
+func runningPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "demo"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{
+				{Name: "nginx", Image: "nginx:1.17"},
+				{Name: "sidecar", Image: "busybox"},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+			PodIPs: []corev1.PodIP{{IP: "172.16.0.5"}, {IP: "fd00::5"}},
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:         "nginx",
+					Ready:        true,
+					RestartCount: 2,
+					State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+					},
+				},
+				{
+					Name:  "sidecar",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				},
+			},
+		},
+	}
+}
+
+func TestPodGet(t *testing.T) {
+	p := &Pod{ClientSet: fake.NewSimpleClientset(runningPod()), PodName: "demo", Namespace: "default"}
+
+	got, err := p.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Spec.NodeName != "node-1" || got.Status.ContainerStatuses[0].RestartCount != 2 {
+		t.Errorf("unexpected pod returned: %+v", got)
+	}
+	if ips := podIPs(got); ips != "172.16.0.5,fd00::5" {
+		t.Errorf("podIPs = %q", ips)
+	}
+	if state := containerState(got.Status.ContainerStatuses[1].State); state != "Waiting (CrashLoopBackOff)" {
+		t.Errorf("containerState = %q", state)
+	}
+
+	p.PodName = "missing"
+	if _, err := p.Get(); !apierrors.IsNotFound(err) {
+		t.Errorf("get missing pod: want NotFound, got %v", err)
+	}
+}
+
+func TestPodUpdate(t *testing.T) {
+	deadline := int64(600)
+	tests := []struct {
+		name    string
+		pod     Pod
+		wantErr bool
+		check   func(t *testing.T, pod *corev1.Pod)
+	}{
+		{
+			name: "labels and annotations",
+			pod: Pod{
+				Labels:      map[string]string{"tier": "web"},
+				Annotations: map[string]string{"owner": "demo"},
+			},
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if pod.Labels["app"] != "demo" || pod.Labels["tier"] != "web" {
+					t.Errorf("labels = %v", pod.Labels)
+				}
+				if pod.Annotations["owner"] != "demo" {
+					t.Errorf("annotations = %v", pod.Annotations)
+				}
+			},
+		},
+		{
+			name: "container image and deadline",
+			pod: Pod{
+				Images:                map[string]string{"sidecar": "busybox:1.35"},
+				ActiveDeadlineSeconds: &deadline,
+			},
+			check: func(t *testing.T, pod *corev1.Pod) {
+				if pod.Spec.Containers[0].Image != "nginx:1.17" || pod.Spec.Containers[1].Image != "busybox:1.35" {
+					t.Errorf("containers = %+v", pod.Spec.Containers)
+				}
+				if pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds != 600 {
+					t.Errorf("activeDeadlineSeconds = %v", pod.Spec.ActiveDeadlineSeconds)
+				}
+			},
+		},
+		{
+			name:    "unknown container",
+			pod:     Pod{Images: map[string]string{"redis": "redis:6"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(runningPod())
+			p := tt.pod
+			p.ClientSet, p.PodName, p.Namespace = client, "demo", "default"
+
+			_, err := p.Update()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("update error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check == nil {
+				return
+			}
+			got, err := client.CoreV1().Pods("default").Get(context.TODO(), "demo", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestPodUpdateRetriesOnConflict(t *testing.T) {
+	client := fake.NewSimpleClientset(runningPod())
+	conflicts := 0
+	client.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			conflicts++
+			return true, nil, apierrors.NewConflict(corev1.Resource("pods"), "demo", fmt.Errorf("object was modified"))
+		}
+		return false, nil, nil
+	})
+
+	p := &Pod{ClientSet: client, PodName: "demo", Namespace: "default", Labels: map[string]string{"tier": "web"}}
+	updated, err := p.Update()
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if conflicts != 1 || updated.Labels["tier"] != "web" {
+		t.Errorf("conflicts = %d, labels = %v", conflicts, updated.Labels)
+	}
+}
+
+func TestPodGetList(t *testing.T) {
+	other := runningPod()
+	other.Name, other.Namespace, other.Labels = "dns", "kube-system", map[string]string{"app": "dns"}
+	client := fake.NewSimpleClientset(runningPod(), other)
+	p := &Pod{ClientSet: client, Namespace: "default"}
+
+	tests := []struct {
+		name string
+		opts query.Options
+		want int
+	}{
+		{name: "namespace", opts: query.Options{}, want: 1},
+		{name: "all namespaces", opts: query.Options{AllNamespaces: true}, want: 2},
+		{name: "label selector", opts: query.Options{AllNamespaces: true, LabelSelector: "app=dns"}, want: 1},
+		{name: "no match", opts: query.Options{LabelSelector: "app=dns"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := p.GetList(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Items) != tt.want {
+				t.Errorf("got %d pods, want %d", len(list.Items), tt.want)
+			}
+		})
+	}
+}
+
+func TestPodErrors(t *testing.T) {
+	gr := corev1.Resource("pods")
+	tests := []struct {
+		name string
+		verb string
+		err  error
+		call func(p *Pod) error
+		want errs.Reason
+	}{
+		{
+			name: "create exists",
+			verb: "create",
+			err:  apierrors.NewAlreadyExists(gr, "demo"),
+			call: func(p *Pod) error { _, err := p.Create(); return err },
+			want: errs.AlreadyExists,
+		},
+		{
+			name: "create invalid",
+			verb: "create",
+			err:  apierrors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Pod").GroupKind(), "demo", nil),
+			call: func(p *Pod) error { _, err := p.Create(); return err },
+			want: errs.Invalid,
+		},
+		{
+			name: "get missing",
+			verb: "get",
+			err:  apierrors.NewNotFound(gr, "demo"),
+			call: func(p *Pod) error { _, err := p.Get(); return err },
+			want: errs.NotFound,
+		},
+		{
+			name: "update forbidden",
+			verb: "update",
+			err:  apierrors.NewForbidden(gr, "demo", fmt.Errorf("pod updates may not change fields other than image")),
+			call: func(p *Pod) error { _, err := p.Update(); return err },
+			want: errs.Forbidden,
+		},
+		{
+			name: "update keeps conflicting",
+			verb: "update",
+			err:  apierrors.NewConflict(gr, "demo", fmt.Errorf("object was modified")),
+			call: func(p *Pod) error { _, err := p.Update(); return err },
+			want: errs.Conflict,
+		},
+		{
+			name: "delete timeout",
+			verb: "delete",
+			err:  apierrors.NewTimeoutError("request timed out", 1),
+			call: func(p *Pod) error { return p.Delete() },
+			want: errs.Timeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(runningPod())
+			client.PrependReactor(tt.verb, "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tt.err
+			})
+			p := &Pod{ClientSet: client, PodName: "demo", Namespace: "default"}
+			err := tt.call(p)
+			if got := errs.Classify(err); got != tt.want {
+				t.Errorf("Classify(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodCreate(t *testing.T) {
+	p := &Pod{ClientSet: fake.NewSimpleClientset(), PodName: "web", Namespace: "default"}
+	created, err := p.Create()
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.Name != "web" {
+		t.Errorf("created pod %q", created.Name)
+	}
+}
@@ -0,0 +1,191 @@
+package printer
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 表格中的一列，值来自JSONPath或者Value函数
+type Column struct {
+	Header   string
+	JSONPath string
+	// CRD additionalPrinterColumns 的类型，date类型显示成时长
+	Type string
+	// 只在 -o wide 时显示
+	Wide bool
+	// 无法用JSONPath表达的列，例如pod的READY
+	Value func(obj *unstructured.Unstructured) string
+}
+
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+var nameColumn = Column{Header: "NAME", JSONPath: ".metadata.name"}
+
+var namespaceColumn = Column{Header: "NAMESPACE", JSONPath: ".metadata.namespace"}
+
+var ageColumn = Column{Header: "AGE", JSONPath: ".metadata.creationTimestamp", Type: "date"}
+
+// 内置kind的默认列，和kubectl get的输出保持一致
+var defaultColumns = map[schema.GroupKind][]Column{
+	{Kind: "Pod"}: {
+		{Header: "READY", Value: podReady},
+		{Header: "STATUS", JSONPath: ".status.phase"},
+		{Header: "RESTARTS", Value: podRestarts},
+		ageColumn,
+		{Header: "IP", JSONPath: ".status.podIP", Wide: true},
+		{Header: "NODE", JSONPath: ".spec.nodeName", Wide: true},
+	},
+	{Group: "apps", Kind: "Deployment"}: {
+		{Header: "READY", Value: deploymentReady},
+		{Header: "UP-TO-DATE", JSONPath: ".status.updatedReplicas"},
+		{Header: "AVAILABLE", JSONPath: ".status.availableReplicas"},
+		ageColumn,
+		{Header: "CONTAINERS", JSONPath: ".spec.template.spec.containers[*].name", Wide: true},
+		{Header: "IMAGES", JSONPath: ".spec.template.spec.containers[*].image", Wide: true},
+		{Header: "SELECTOR", Value: selector, Wide: true},
+	},
+}
+
+// 内置kind的默认列，其余kind只显示AGE
+func DefaultColumns(gk schema.GroupKind) []Column {
+	if columns, ok := defaultColumns[gk]; ok {
+		return columns
+	}
+	return []Column{ageColumn}
+}
+
+// 读取CRD中对应版本的additionalPrinterColumns，priority大于0的列只在wide时显示
+func CRDColumns(client dynamic.Interface, gvr schema.GroupVersionResource) ([]Column, error) {
+	crd, err := client.Resource(crdResource).Get(context.TODO(), gvr.Resource+"."+gvr.Group, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["name"] != gvr.Version {
+			continue
+		}
+		printerColumns, _, err := unstructured.NestedSlice(version, "additionalPrinterColumns")
+		if err != nil {
+			return nil, err
+		}
+
+		var columns []Column
+		for _, c := range printerColumns {
+			column, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(column, "name")
+			jsonPath, _, _ := unstructured.NestedString(column, "jsonPath")
+			columnType, _, _ := unstructured.NestedString(column, "type")
+			priority, _, _ := unstructured.NestedInt64(column, "priority")
+			columns = append(columns, Column{
+				Header:   strings.ToUpper(name),
+				JSONPath: jsonPath,
+				Type:     columnType,
+				Wide:     priority > 0,
+			})
+		}
+		return columns, nil
+	}
+	return nil, fmt.Errorf("version %s not found in CustomResourceDefinition %s", gvr.Version, crd.GetName())
+}
+
+func (c Column) value(obj *unstructured.Unstructured) (string, error) {
+	if c.Value != nil {
+		return c.Value(obj), nil
+	}
+
+	parser, err := c.parse()
+	if err != nil {
+		return "", err
+	}
+	results, err := parser.FindResults(obj.Object)
+	if err != nil {
+		return "", err
+	}
+
+	var values []string
+	for _, result := range results {
+		for _, v := range result {
+			// typed对象转换后未设置的字段是null
+			if v.Interface() == nil {
+				continue
+			}
+			values = append(values, fmt.Sprint(v.Interface()))
+		}
+	}
+	if len(values) == 0 {
+		return "<none>", nil
+	}
+	if c.Type == "date" {
+		return age(values[0]), nil
+	}
+	return strings.Join(values, ","), nil
+}
+
+// 把时间戳显示成 5m、3d 这样的时长
+func age(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return duration.HumanDuration(time.Since(t))
+}
+
+func podReady(obj *unstructured.Unstructured) string {
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "containers")
+	ready := 0
+	for _, s := range statuses {
+		if status, ok := s.(map[string]interface{}); ok && status["ready"] == true {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(containers))
+}
+
+func podRestarts(obj *unstructured.Unstructured) string {
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	var restarts int64
+	for _, s := range statuses {
+		if status, ok := s.(map[string]interface{}); ok {
+			count, _, _ := unstructured.NestedInt64(status, "restartCount")
+			restarts += count
+		}
+	}
+	return fmt.Sprint(restarts)
+}
+
+func deploymentReady(obj *unstructured.Unstructured) string {
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	return fmt.Sprintf("%d/%d", ready, replicas)
+}
+
+func selector(obj *unstructured.Unstructured) string {
+	labels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels")
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
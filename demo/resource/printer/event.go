@@ -0,0 +1,96 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+	"text/tabwriter"
+)
+
+// watch时逐个输出 ADDED/MODIFIED/DELETED 事件
+type EventPrinter interface {
+	PrintEvent(w io.Writer, eventType string, obj *unstructured.Unstructured) error
+}
+
+// 和New的参数一致。表格在最前面加一列EVENT，表头只输出一次；
+// json、yaml输出成 {"type": ..., "object": ...}，和kubectl --output-watch-events一致
+func NewEventPrinter(output string, columns []Column, withNamespace bool) (EventPrinter, error) {
+	p, err := New(output, columns, withNamespace)
+	if err != nil {
+		return nil, err
+	}
+	switch p := p.(type) {
+	case *tablePrinter:
+		return &eventTablePrinter{table: p}, nil
+	case *jsonPrinter:
+		return &eventJSONPrinter{}, nil
+	case *yamlPrinter:
+		return &eventYAMLPrinter{}, nil
+	case *namePrinter:
+		return &eventNamePrinter{}, nil
+	}
+	return nil, fmt.Errorf("output format %q does not support watch", output)
+}
+
+func watchEvent(eventType string, obj *unstructured.Unstructured) map[string]interface{} {
+	return map[string]interface{}{"type": eventType, "object": obj.Object}
+}
+
+var eventColumn = Column{Header: "EVENT"}
+
+type eventTablePrinter struct {
+	table         *tablePrinter
+	headerPrinted bool
+}
+
+// 每个事件都立即flush，和kubectl一样各行之间不保证对齐
+func (p *eventTablePrinter) PrintEvent(w io.Writer, eventType string, obj *unstructured.Unstructured) error {
+	columns := p.table.visibleColumns()
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	if !p.headerPrinted {
+		fmt.Fprintln(tw, headerLine(append([]Column{eventColumn}, columns...)))
+		p.headerPrinted = true
+	}
+	line, err := rowLine(columns, obj)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(tw, eventType+"\t"+line)
+	return tw.Flush()
+}
+
+type eventJSONPrinter struct{}
+
+func (p *eventJSONPrinter) PrintEvent(w io.Writer, eventType string, obj *unstructured.Unstructured) error {
+	data, err := json.MarshalIndent(watchEvent(eventType, obj), "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+type eventYAMLPrinter struct{}
+
+func (p *eventYAMLPrinter) PrintEvent(w io.Writer, eventType string, obj *unstructured.Unstructured) error {
+	data, err := yaml.Marshal(watchEvent(eventType, obj))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", data)
+	return err
+}
+
+// 输出 ADDED pod/web
+type eventNamePrinter struct {
+	names namePrinter
+}
+
+func (p *eventNamePrinter) PrintEvent(w io.Writer, eventType string, obj *unstructured.Unstructured) error {
+	if _, err := fmt.Fprint(w, eventType, " "); err != nil {
+		return err
+	}
+	return p.names.Print(w, []unstructured.Unstructured{*obj}, false)
+}
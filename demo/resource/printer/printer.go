@@ -0,0 +1,237 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"text/tabwriter"
+)
+
+// 把查询结果按 -o 指定的格式输出。list表示结果来自list请求，
+// json、yaml按kubectl的方式输出成List，即使只有一个或者没有对象
+type Printer interface {
+	Print(w io.Writer, objs []unstructured.Unstructured, list bool) error
+}
+
+// 根据 -o 参数创建printer：json、yaml、name、wide、custom-columns=...，为空时输出表格。
+// columns是表格默认的列（不含NAME），例如CRD的additionalPrinterColumns；
+// withNamespace为true时表格的第一列是NAMESPACE，用于列出所有命名空间
+func New(output string, columns []Column, withNamespace bool) (Printer, error) {
+	switch {
+	case output == "":
+		return &tablePrinter{columns: columns, withNamespace: withNamespace}, nil
+	case output == "wide":
+		return &tablePrinter{columns: columns, wide: true, withNamespace: withNamespace}, nil
+	case output == "json":
+		return &jsonPrinter{}, nil
+	case output == "yaml":
+		return &yamlPrinter{}, nil
+	case output == "name":
+		return &namePrinter{}, nil
+	case strings.HasPrefix(output, "custom-columns="):
+		columns, err := parseCustomColumns(strings.TrimPrefix(output, "custom-columns="))
+		if err != nil {
+			return nil, err
+		}
+		return &tablePrinter{columns: columns, custom: true}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, supported: json|yaml|name|wide|custom-columns=", output)
+}
+
+// typed对象转成unstructured，并补上apiVersion和kind，List中的对象通常不带这两个字段
+func ToUnstructured(obj runtime.Object, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+type jsonPrinter struct{}
+
+func (p *jsonPrinter) Print(w io.Writer, objs []unstructured.Unstructured, list bool) error {
+	data, err := json.MarshalIndent(document(objs, list), "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+type yamlPrinter struct{}
+
+func (p *yamlPrinter) Print(w io.Writer, objs []unstructured.Unstructured, list bool) error {
+	data, err := yaml.Marshal(document(objs, list))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// 按名字查询的单个对象直接输出，list的结果输出成List，和kubectl一致
+func document(objs []unstructured.Unstructured, list bool) interface{} {
+	if !list && len(objs) == 1 {
+		return objs[0].Object
+	}
+	items := make([]interface{}, 0, len(objs))
+	for _, obj := range objs {
+		items = append(items, obj.Object)
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{"resourceVersion": ""},
+		"items":      items,
+	}
+}
+
+// 输出 kind.group/name，例如 deployment.apps/demo
+type namePrinter struct{}
+
+func (p *namePrinter) Print(w io.Writer, objs []unstructured.Unstructured, list bool) error {
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		resource := strings.ToLower(gvk.Kind)
+		if gvk.Group != "" {
+			resource += "." + gvk.Group
+		}
+		if _, err := fmt.Fprintf(w, "%s/%s\n", resource, obj.GetName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tablePrinter struct {
+	columns       []Column
+	wide          bool
+	withNamespace bool
+	// custom-columns 不自动加NAME列
+	custom bool
+}
+
+func (p *tablePrinter) Print(w io.Writer, objs []unstructured.Unstructured, list bool) error {
+	// 没有结果时不输出表头，和kubectl一致
+	if len(objs) == 0 {
+		_, err := fmt.Fprintln(w, "No resources found")
+		return err
+	}
+	columns := p.visibleColumns()
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, headerLine(columns))
+	for i := range objs {
+		line, err := rowLine(columns, &objs[i])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
+
+// 表格实际输出的列：NAMESPACE、NAME和非wide时需要显示的列
+func (p *tablePrinter) visibleColumns() []Column {
+	columns := make([]Column, 0, len(p.columns)+2)
+	if p.withNamespace {
+		columns = append(columns, namespaceColumn)
+	}
+	if !p.custom {
+		columns = append(columns, nameColumn)
+	}
+	for _, column := range p.columns {
+		if column.Wide && !p.wide {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func headerLine(columns []Column) string {
+	headers := make([]string, 0, len(columns))
+	for _, column := range columns {
+		headers = append(headers, column.Header)
+	}
+	return strings.Join(headers, "\t")
+}
+
+func rowLine(columns []Column, obj *unstructured.Unstructured) (string, error) {
+	values := make([]string, 0, len(columns))
+	for _, column := range columns {
+		value, err := column.value(obj)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, "\t"), nil
+}
+
+// 解析 HEADER:.path,HEADER:.path
+func parseCustomColumns(spec string) ([]Column, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("custom-columns format specified but no custom columns given")
+	}
+	var columns []Column
+	for _, part := range splitColumns(spec) {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("unexpected custom-columns spec: %q, expected <header>:<json-path-expr>", part)
+		}
+		column := Column{Header: kv[0], JSONPath: kv[1]}
+		if _, err := column.parse(); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// 按逗号分隔列，{}、[]、()和引号里的逗号属于jsonpath，例如 {.a,.b}、.items[0,1]
+func splitColumns(spec string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range spec {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '{' || r == '[' || r == '(':
+			depth++
+		case r == '}' || r == ']' || r == ')':
+			if depth > 0 {
+				depth--
+			}
+		case r == ',' && depth == 0:
+			parts = append(parts, spec[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, spec[start:])
+}
+
+// 把 .spec.replicas 或 {.spec.replicas} 解析成jsonpath
+func (c Column) parse() (*jsonpath.JSONPath, error) {
+	expr := c.JSONPath
+	if !strings.HasPrefix(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	parser := jsonpath.New(c.Header).AllowMissingKeys(true)
+	if err := parser.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q for column %s: %v", c.JSONPath, c.Header, err)
+	}
+	return parser, nil
+}
@@ -0,0 +1,297 @@
+package printer
+
+import (
+	"bytes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"reflect"
+	"resource-demo/manifest"
+	"strings"
+	"testing"
+)
+
+var redisGVR = schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v1", Resource: "redis"}
+
+func redis(name string, replicas int64) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cs.handpay.cn/v1",
+		"kind":       "Redis",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"spec": map[string]interface{}{
+			"schedule": "2022-11-17T10:12:00Z",
+			"command":  "echo redis crd2!",
+			"replicas": replicas,
+			"phase":    "Running",
+		},
+	}}
+}
+
+func pod(t *testing.T) unstructured.Unstructured {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "nginx", Image: "nginx"}, {Name: "sidecar", Image: "busybox"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: "172.16.0.5",
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "nginx", Ready: true, RestartCount: 1},
+				{Name: "sidecar", RestartCount: 2},
+			},
+		},
+	}
+	u, err := ToUnstructured(p, corev1.SchemeGroupVersion.WithKind("Pod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *u
+}
+
+// list请求的结果
+func print(t *testing.T, output string, columns []Column, objs ...unstructured.Unstructured) string {
+	return printResult(t, output, columns, true, objs...)
+}
+
+func printResult(t *testing.T, output string, columns []Column, list bool, objs ...unstructured.Unstructured) string {
+	p, err := New(output, columns, false)
+	if err != nil {
+		t.Fatalf("New(%q): %v", output, err)
+	}
+	var buf bytes.Buffer
+	if err := p.Print(&buf, objs, list); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	return buf.String()
+}
+
+func TestPrintFormats(t *testing.T) {
+	podColumns := DefaultColumns(schema.GroupKind{Kind: "Pod"})
+	tests := []struct {
+		name    string
+		output  string
+		columns []Column
+		// 按名字查询的单个对象
+		single bool
+		objs   []unstructured.Unstructured
+		want   string
+	}{
+		{
+			name:    "pod table",
+			columns: podColumns,
+			objs:    []unstructured.Unstructured{pod(t)},
+			want: "NAME   READY   STATUS    RESTARTS   AGE\n" +
+				"web    1/2     Running   3          <none>\n",
+		},
+		{
+			name:    "pod wide",
+			output:  "wide",
+			columns: podColumns,
+			objs:    []unstructured.Unstructured{pod(t)},
+			want: "NAME   READY   STATUS    RESTARTS   AGE      IP           NODE\n" +
+				"web    1/2     Running   3          <none>   172.16.0.5   node-1\n",
+		},
+		{
+			name:   "name",
+			output: "name",
+			objs:   []unstructured.Unstructured{pod(t), redis("test", 2)},
+			want:   "pod/web\nredis.cs.handpay.cn/test\n",
+		},
+		{
+			name:   "custom columns",
+			output: "custom-columns=NAME:.metadata.name,IMAGES:.spec.containers[*].image,NODE:{.spec.nodeName}",
+			objs:   []unstructured.Unstructured{pod(t)},
+			want: "NAME   IMAGES          NODE\n" +
+				"web    nginx,busybox   node-1\n",
+		},
+		{
+			name:   "custom columns with commas in jsonpath",
+			output: `custom-columns=NAME:.metadata.name,CONTAINERS:{.spec.containers[0,1].name},STATUS:{.status['phase','podIP']}`,
+			objs:   []unstructured.Unstructured{pod(t)},
+			want: "NAME   CONTAINERS      STATUS\n" +
+				"web    nginx,sidecar   Running,172.16.0.5\n",
+		},
+		{
+			name:   "yaml single object",
+			output: "yaml",
+			single: true,
+			objs:   []unstructured.Unstructured{redis("test", 2)},
+			want: "apiVersion: cs.handpay.cn/v1\nkind: Redis\nmetadata:\n  name: test\n  namespace: default\n" +
+				"spec:\n  command: echo redis crd2!\n  phase: Running\n  replicas: 2\n  schedule: \"2022-11-17T10:12:00Z\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := printResult(t, tt.output, tt.columns, !tt.single, tt.objs...); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintJSONList(t *testing.T) {
+	got := print(t, "json", nil, redis("a", 1), redis("b", 2))
+	for _, want := range []string{`"kind": "List"`, `"name": "a"`, `"name": "b"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("json output missing %s:\n%s", want, got)
+		}
+	}
+
+	// list只返回一个对象时仍然是List
+	got = print(t, "json", nil, redis("a", 1))
+	if !strings.Contains(got, `"kind": "List"`) {
+		t.Errorf("list with one item not printed as List:\n%s", got)
+	}
+	got = printResult(t, "json", nil, false, redis("a", 1))
+	if !strings.Contains(got, `"kind": "Redis"`) || strings.Contains(got, `"kind": "List"`) {
+		t.Errorf("single object printed as List:\n%s", got)
+	}
+	got = print(t, "yaml", nil)
+	if !strings.Contains(got, "kind: List") || !strings.Contains(got, "items: []") {
+		t.Errorf("empty list:\n%s", got)
+	}
+}
+
+func TestPrintEmptyTable(t *testing.T) {
+	for _, output := range []string{"", "wide", "custom-columns=NAME:.metadata.name"} {
+		if got := print(t, output, DefaultColumns(schema.GroupKind{Kind: "Pod"})); got != "No resources found\n" {
+			t.Errorf("-o %q: got %q", output, got)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, output := range []string{"xml", "custom-columns=", "custom-columns=NAME", "custom-columns=NAME:{.metadata.name"} {
+		if _, err := New(output, nil, false); err == nil {
+			t.Errorf("New(%q): want error", output)
+		}
+	}
+}
+
+func TestSplitColumns(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"NAME:.metadata.name", []string{"NAME:.metadata.name"}},
+		{"A:.a,B:.b", []string{"A:.a", "B:.b"}},
+		{"A:{.a,.b},B:.b", []string{"A:{.a,.b}", "B:.b"}},
+		{"A:.items[0,1],B:.b", []string{"A:.items[0,1]", "B:.b"}},
+		{`A:{.items[?(@.name=="x,y")].id},B:.b`, []string{`A:{.items[?(@.name=="x,y")].id}`, "B:.b"}},
+		{"A:.a,", []string{"A:.a", ""}},
+	}
+	for _, tt := range tests {
+		if got := splitColumns(tt.spec); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitColumns(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestCRDColumns(t *testing.T) {
+	objects, err := manifest.Load("../crd/yml/crd.yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), objects[0])
+
+	columns, err := CRDColumns(client, redisGVR)
+	if err != nil {
+		t.Fatalf("CRDColumns: %v", err)
+	}
+	var headers []string
+	for _, column := range columns {
+		headers = append(headers, column.Header)
+	}
+	if strings.Join(headers, ",") != "SCHEDULE,COMMAND,AGE,REPLICAS,PHASE" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	want := "NAME   SCHEDULE               COMMAND            AGE      REPLICAS   PHASE\n" +
+		"test   2022-11-17T10:12:00Z   echo redis crd2!   <none>   2          Running\n"
+	if got := print(t, "", columns, redis("test", 2)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// 每个版本有自己的列
+	columns, err = CRDColumns(client, schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v2", Resource: "redis"})
+	if err != nil {
+		t.Fatalf("CRDColumns v2: %v", err)
+	}
+	if len(columns) != 4 || columns[0].Header != "SCHEDULE" {
+		t.Errorf("v2 columns = %+v", columns)
+	}
+
+	if _, err := CRDColumns(client, schema.GroupVersionResource{Group: "cs.handpay.cn", Version: "v3", Resource: "redis"}); err == nil {
+		t.Error("unknown version: want error")
+	}
+}
+
+func TestPrintWithNamespace(t *testing.T) {
+	p, err := New("", DefaultColumns(schema.GroupKind{Group: "cs.handpay.cn", Kind: "Redis"}), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.Print(&buf, []unstructured.Unstructured{redis("test", 2)}, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "NAMESPACE   NAME   AGE\n" +
+		"default     test   <none>\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestEventPrinter(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{
+			output: "",
+			want: "EVENT   NAME   AGE\n" +
+				"ADDED   test   <none>\n" +
+				"DELETED   test   <none>\n",
+		},
+		{
+			output: "name",
+			want:   "ADDED redis.cs.handpay.cn/test\nDELETED redis.cs.handpay.cn/test\n",
+		},
+		{
+			output: "yaml",
+			want: "---\nobject:\n  apiVersion: cs.handpay.cn/v1\n  kind: Redis\n  metadata:\n    name: test\n    namespace: default\n" +
+				"  spec:\n    command: echo redis crd2!\n    phase: Running\n    replicas: 1\n    schedule: \"2022-11-17T10:12:00Z\"\ntype: ADDED\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			p, err := NewEventPrinter(tt.output, DefaultColumns(schema.GroupKind{Group: "cs.handpay.cn", Kind: "Redis"}), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := redis("test", 1)
+			var buf bytes.Buffer
+			for _, eventType := range []string{"ADDED", "DELETED"} {
+				if err := p.PrintEvent(&buf, eventType, &obj); err != nil {
+					t.Fatal(err)
+				}
+				if tt.output == "yaml" {
+					break
+				}
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := NewEventPrinter("xml", nil, false); err == nil {
+		t.Error("unknown output: want error")
+	}
+}
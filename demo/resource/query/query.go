@@ -0,0 +1,71 @@
+package query
+
+import (
+	"context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// 默认每页的条数，和kubectl的--chunk-size一致
+const DefaultChunkSize = 500
+
+// search时的过滤和分页参数
+type Options struct {
+	LabelSelector string
+	FieldSelector string
+	AllNamespaces bool
+	// 每次请求最多返回的条数，0表示不分页
+	ChunkSize int64
+}
+
+func (o Options) ListOptions() metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: o.LabelSelector,
+		FieldSelector: o.FieldSelector,
+		Limit:         o.ChunkSize,
+	}
+}
+
+// 查询所有命名空间时namespace为空
+func (o Options) Namespace(namespace string) string {
+	if o.AllNamespaces {
+		return ""
+	}
+	return namespace
+}
+
+// 按Continue token分页，fetch处理一页并返回下一页的token
+func Paginate(opts metav1.ListOptions, fetch func(opts metav1.ListOptions) (string, error)) error {
+	for {
+		next, err := fetch(opts)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		opts.Continue = next
+	}
+}
+
+// 通过dynamic客户端分页取出所有结果，合并成一个List
+func ListAll(client dynamic.ResourceInterface, o Options) (*unstructured.UnstructuredList, error) {
+	result := &unstructured.UnstructuredList{}
+	err := Paginate(o.ListOptions(), func(opts metav1.ListOptions) (string, error) {
+		list, err := client.List(context.TODO(), opts)
+		if err != nil {
+			return "", err
+		}
+		if result.Object == nil {
+			result.Object = list.Object
+		}
+		result.Items = append(result.Items, list.Items...)
+		return list.GetContinue(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.SetContinue("")
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"reflect"
+	"testing"
+)
+
+var deploymentRes = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+func deployment(namespace, name string, labels map[string]interface{}) runtime.Object {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace, "labels": labels},
+	}}
+}
+
+func names(list *unstructured.UnstructuredList) []string {
+	var result []string
+	for _, item := range list.Items {
+		result = append(result, item.GetNamespace()+"/"+item.GetName())
+	}
+	return result
+}
+
+func TestPaginate(t *testing.T) {
+	pages := map[string]string{"": "page2", "page2": "page3", "page3": ""}
+	var requests []metav1.ListOptions
+	err := Paginate(Options{LabelSelector: "app=demo", ChunkSize: 2}.ListOptions(), func(opts metav1.ListOptions) (string, error) {
+		requests = append(requests, opts)
+		return pages[opts.Continue], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var continues []string
+	for _, opts := range requests {
+		if opts.Limit != 2 || opts.LabelSelector != "app=demo" {
+			t.Errorf("request lost options: %+v", opts)
+		}
+		continues = append(continues, opts.Continue)
+	}
+	if want := []string{"", "page2", "page3"}; !reflect.DeepEqual(continues, want) {
+		t.Errorf("continue tokens = %v, want %v", continues, want)
+	}
+}
+
+func TestPaginateError(t *testing.T) {
+	calls := 0
+	err := Paginate(metav1.ListOptions{}, func(opts metav1.ListOptions) (string, error) {
+		calls++
+		if calls == 2 {
+			return "", fmt.Errorf("expired")
+		}
+		return "next", nil
+	})
+	if err == nil || calls != 2 {
+		t.Errorf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestListAll(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentRes: "DeploymentList"},
+		deployment("default", "web", map[string]interface{}{"app": "demo"}),
+		deployment("default", "db", map[string]interface{}{"app": "redis"}),
+		deployment("kube-system", "dns", map[string]interface{}{"app": "demo"}),
+	)
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{name: "namespace", opts: Options{}, want: []string{"default/db", "default/web"}},
+		{name: "label selector", opts: Options{LabelSelector: "app=demo"}, want: []string{"default/web"}},
+		{name: "all namespaces", opts: Options{AllNamespaces: true, LabelSelector: "app=demo"}, want: []string{"default/web", "kube-system/dns"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ListAll(client.Resource(deploymentRes).Namespace(tt.opts.Namespace("default")), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fake dynamic客户端的List会丢掉continue，这里直接模拟分页的服务端
+type pagedClient struct {
+	dynamic.ResourceInterface
+	requests []metav1.ListOptions
+}
+
+func (c *pagedClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	c.requests = append(c.requests, opts)
+	page := len(c.requests)
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "apps/v1", "kind": "DeploymentList"}}
+	list.Items = append(list.Items, *deployment("default", fmt.Sprintf("web-%d", page), nil).(*unstructured.Unstructured))
+	if page < 3 {
+		list.SetContinue(fmt.Sprintf("token-%d", page))
+	}
+	return list, nil
+}
+
+func TestListAllFollowsContinue(t *testing.T) {
+	client := &pagedClient{}
+	list, err := ListAll(client, Options{ChunkSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"default/web-1", "default/web-2", "default/web-3"}; !reflect.DeepEqual(names(list), want) {
+		t.Errorf("got %v, want %v", names(list), want)
+	}
+	if list.GetContinue() != "" {
+		t.Errorf("merged list should not carry a continue token, got %q", list.GetContinue())
+	}
+
+	var continues []string
+	for _, opts := range client.requests {
+		continues = append(continues, opts.Continue)
+	}
+	if want := []string{"", "token-1", "token-2"}; !reflect.DeepEqual(continues, want) {
+		t.Errorf("continue tokens = %v, want %v", continues, want)
+	}
+}
@@ -0,0 +1,199 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"time"
+)
+
+// 处理一个事件，Object是*unstructured.Unstructured，返回错误时停止watch
+type EventHandler func(event watch.Event) error
+
+// 先list再从list的resourceVersion开始watch，list到的对象以ADDED事件输出。
+// 连接断开或者list、watch出现临时错误时等待退避时间后从最后的resourceVersion继续；resourceVersion过期（410 Gone）时重新list，
+// 和之前的结果比较后补发ADDED、MODIFIED、DELETED事件。ctx取消时返回nil，重试也不会成功的错误（例如没有权限）和handle的错误直接返回
+func Watch(ctx context.Context, client dynamic.ResourceInterface, o Options, handle EventHandler) error {
+	return newWatcher(client, o, handle, clock.RealClock{}).run(ctx)
+}
+
+// 重新watch的退避时间，和client-go的Reflector一致：从800ms开始翻倍，最多30s，2分钟没有断开后重置
+const (
+	initialBackoff = 800 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+	resetBackoff   = 2 * time.Minute
+)
+
+type watcher struct {
+	client  dynamic.ResourceInterface
+	options Options
+	handle  EventHandler
+	// 已经输出过的对象，key为 namespace/name
+	known map[string]*unstructured.Unstructured
+	// 连接反复立刻断开时，避免不停地重新watch
+	backoff wait.BackoffManager
+}
+
+func newWatcher(client dynamic.ResourceInterface, o Options, handle EventHandler, c clock.Clock) *watcher {
+	return &watcher{
+		client:  client,
+		options: o,
+		handle:  handle,
+		known:   map[string]*unstructured.Unstructured{},
+		backoff: wait.NewExponentialBackoffManager(initialBackoff, maxBackoff, resetBackoff, 2.0, 1.0, c),
+	}
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	var resourceVersion string
+	// 第一次以及resourceVersion过期时需要list
+	needList := true
+	for {
+		var err error
+		if needList {
+			var listed string
+			if listed, err = w.relist(ctx); err == nil {
+				resourceVersion, needList = listed, false
+				continue
+			}
+		} else {
+			resourceVersion, err = w.watch(ctx, resourceVersion)
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				needList = true
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		var transient *transientError
+		if err != nil && !errors.As(err, &transient) {
+			return err
+		}
+		if err != nil {
+			klog.Infof("%v，退避之后重试", err)
+		}
+		// 连接断开或者临时错误，退避之后再重试
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.backoff.Backoff().C():
+		}
+	}
+}
+
+// list、watch请求的临时错误，例如连接失败、apiserver过载，退避之后重试
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// 请求本身有问题时重试也不会成功，其余的错误标记为临时错误
+func requestError(err error) error {
+	switch {
+	case apierrors.IsResourceExpired(err), apierrors.IsGone(err),
+		apierrors.IsForbidden(err), apierrors.IsUnauthorized(err), apierrors.IsNotFound(err),
+		apierrors.IsBadRequest(err), apierrors.IsInvalid(err), apierrors.IsMethodNotSupported(err):
+		return err
+	}
+	return &transientError{err: err}
+}
+
+func key(obj *unstructured.Unstructured) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// 重新list，返回list的resourceVersion
+func (w *watcher) relist(ctx context.Context) (string, error) {
+	list, err := ListAll(w.client, w.options)
+	if err != nil {
+		return "", requestError(err)
+	}
+
+	current := make(map[string]bool, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		current[key(obj)] = true
+		eventType := watch.Modified
+		if old, ok := w.known[key(obj)]; !ok {
+			eventType = watch.Added
+		} else if old.GetResourceVersion() == obj.GetResourceVersion() {
+			continue
+		}
+		if err := w.dispatch(eventType, obj); err != nil {
+			return "", err
+		}
+	}
+	// watch断开期间被删除的对象
+	for k, obj := range w.known {
+		if current[k] {
+			continue
+		}
+		if err := w.dispatch(watch.Deleted, obj); err != nil {
+			return "", err
+		}
+	}
+	return list.GetResourceVersion(), nil
+}
+
+// 从resourceVersion开始watch，直到连接断开、收到错误或ctx取消，返回最后的resourceVersion
+func (w *watcher) watch(ctx context.Context, resourceVersion string) (string, error) {
+	opts := w.options.ListOptions()
+	opts.Limit = 0
+	opts.ResourceVersion = resourceVersion
+	opts.AllowWatchBookmarks = true
+	wi, err := w.client.Watch(ctx, opts)
+	if err != nil {
+		return resourceVersion, requestError(err)
+	}
+	defer wi.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion, nil
+		case event, ok := <-wi.ResultChan():
+			if !ok {
+				return resourceVersion, nil
+			}
+			if event.Type == watch.Error {
+				return resourceVersion, requestError(apierrors.FromObject(event.Object))
+			}
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				return resourceVersion, fmt.Errorf("unexpected object %T in %s event", event.Object, event.Type)
+			}
+			resourceVersion = obj.GetResourceVersion()
+			// bookmark只用来推进resourceVersion，不输出
+			if event.Type == watch.Bookmark {
+				continue
+			}
+			if err := w.dispatch(event.Type, obj); err != nil {
+				return resourceVersion, err
+			}
+		}
+	}
+}
+
+func (w *watcher) dispatch(eventType watch.EventType, obj *unstructured.Unstructured) error {
+	if eventType == watch.Deleted {
+		delete(w.known, key(obj))
+	} else {
+		w.known[key(obj)] = obj
+	}
+	return w.handle(watch.Event{Type: eventType, Object: obj})
+}
@@ -0,0 +1,226 @@
+package query
+
+import (
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func versioned(name, resourceVersion string) *unstructured.Unstructured {
+	obj := deployment("default", name, nil).(*unstructured.Unstructured)
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+func TestWatch(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentRes: "DeploymentList"},
+		versioned("web", "1"), versioned("db", "1"),
+	)
+	tracker := client.Tracker()
+
+	watchers := []*watch.FakeWatcher{watch.NewFake(), watch.NewFake(), watch.NewFake()}
+	var requests []string
+	client.PrependWatchReactor("deployments", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		restrictions := action.(k8stesting.WatchActionImpl).WatchRestrictions
+		requests = append(requests, restrictions.ResourceVersion)
+		w := watchers[0]
+		watchers = watchers[1:]
+		return true, w, nil
+	})
+
+	first, second := watchers[0], watchers[1]
+	go func() {
+		first.Add(versioned("cache", "3"))
+		first.Modify(versioned("web", "4"))
+		first.Action(watch.Bookmark, versioned("", "5"))
+		// 连接断开，从bookmark的resourceVersion继续watch
+		first.Stop()
+
+		// 断开期间db被删除，resourceVersion过期后重新list才能发现
+		for _, obj := range []*unstructured.Unstructured{versioned("cache", "3"), versioned("web", "4")} {
+			if err := tracker.Update(deploymentRes, obj, "default"); err != nil {
+				if err := tracker.Add(obj); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+		if err := tracker.Delete(deploymentRes, "default", "db"); err != nil {
+			t.Error(err)
+		}
+		expired := apierrors.NewResourceExpired("too old resource version: 5")
+		second.Error(&expired.ErrStatus)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var events []string
+	err := Watch(ctx, client.Resource(deploymentRes).Namespace("default"), Options{}, func(event watch.Event) error {
+		obj := event.Object.(*unstructured.Unstructured)
+		events = append(events, string(event.Type)+" "+obj.GetName())
+		if event.Type == watch.Deleted {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		t.Fatal("watch did not finish")
+	}
+
+	want := []string{"ADDED db", "ADDED web", "ADDED cache", "MODIFIED web", "DELETED db"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if len(requests) < 2 || requests[1] != "5" {
+		t.Errorf("watch resourceVersions = %v, want the second watch to resume from the bookmark", requests)
+	}
+	if len(requests) != 3 {
+		t.Errorf("want a new watch after relisting, got requests %v", requests)
+	}
+}
+
+func TestWatchBackoff(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentRes: "DeploymentList"},
+		versioned("web", "1"),
+	)
+	// 每次watch的连接都立刻断开
+	requests := make(chan struct{}, 10)
+	client.PrependWatchReactor("deployments", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		w.Stop()
+		requests <- struct{}{}
+		return true, w, nil
+	})
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	w := newWatcher(client.Resource(deploymentRes).Namespace("default"), Options{}, func(watch.Event) error { return nil }, fakeClock)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.run(ctx) }()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-requests:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watch %d was not started", i+1)
+		}
+		// 断开之后等待退避，时间没到不会重新watch
+		if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("watch %d: not backing off after the connection closed", i+1)
+		}
+		select {
+		case <-requests:
+			t.Fatalf("watch %d was restarted without backoff", i+1)
+		case <-time.After(10 * time.Millisecond):
+		}
+		fakeClock.Step(2 * maxBackoff)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("run: %v", err)
+	}
+}
+
+// watch请求的临时错误退避之后重试，没有权限等错误直接返回
+func TestWatchRequestErrors(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentRes: "DeploymentList"},
+		versioned("web", "1"),
+	)
+	watcher := watch.NewFake()
+	errs := []error{
+		apierrors.NewServiceUnavailable("overloaded"),
+		apierrors.NewInternalError(errors.New("etcd")),
+		nil,
+		apierrors.NewForbidden(deploymentRes.GroupResource(), "", errors.New("denied")),
+	}
+	requests := make(chan struct{}, 10)
+	client.PrependWatchReactor("deployments", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		err := errs[0]
+		errs = errs[1:]
+		requests <- struct{}{}
+		if err != nil {
+			return true, nil, err
+		}
+		return true, watcher, nil
+	})
+
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	var events []string
+	w := newWatcher(client.Resource(deploymentRes).Namespace("default"), Options{}, func(event watch.Event) error {
+		events = append(events, string(event.Type)+" "+event.Object.(*unstructured.Unstructured).GetName())
+		return nil
+	}, fakeClock)
+	done := make(chan error)
+	go func() { done <- w.run(context.Background()) }()
+
+	// 两次临时错误，每次都退避之后重试
+	for i := 0; i < 3; i++ {
+		select {
+		case <-requests:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watch %d was not started", i+1)
+		}
+		if i == 2 {
+			break
+		}
+		if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("watch %d: not backing off after a transient error", i+1)
+		}
+		fakeClock.Step(2 * maxBackoff)
+	}
+	watcher.Add(versioned("cache", "2"))
+	watcher.Stop()
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		return fakeClock.HasWaiters(), nil
+	}); err != nil {
+		t.Fatal("not backing off after the connection closed")
+	}
+	fakeClock.Step(2 * maxBackoff)
+
+	select {
+	case err := <-done:
+		if !apierrors.IsForbidden(err) {
+			t.Errorf("run = %v, want forbidden", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return on a forbidden error")
+	}
+	if want := []string{"ADDED web", "ADDED cache"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestWatchHandlerError(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentRes: "DeploymentList"},
+		versioned("web", "1"),
+	)
+	stop := apierrors.NewBadRequest("stop")
+	err := Watch(context.Background(), client.Resource(deploymentRes).Namespace("default"), Options{}, func(event watch.Event) error {
+		return stop
+	})
+	if err != stop {
+		t.Errorf("err = %v, want the handler's error", err)
+	}
+}
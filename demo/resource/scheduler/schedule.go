@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	redisapi "resource-demo/apis/redis"
+	"time"
+)
+
+// 和CronJob一样，错过的计划超过100次时不再逐个计算
+const maxMissedSchedules = 100
+
+// 返回(after, now]之间最近的一次计划时间和其间计划时间的个数，没有时返回零值。
+// 错过的计划（例如scheduler停止期间）只补执行最近的一次。
+// 只执行一次的schedule还没有执行过时after为零值，即使对象在计划时间之后才创建也会执行。
+// 错过超过maxMissedSchedules次时不再逐个计算，个数是估算的
+func mostRecent(s redisapi.Schedule, after, now time.Time) (time.Time, int) {
+	var last, previous time.Time
+	count := 0
+	for t := s.Next(after); !t.IsZero() && !t.After(now); t = s.Next(t) {
+		previous, last = last, t
+		count++
+		if count == maxMissedSchedules {
+			break
+		}
+	}
+	if count < maxMissedSchedules {
+		return last, count
+	}
+
+	// 按第100次前后的间隔估算错过的个数，再从now往前找最近的一次，找不到时加倍往前找的范围
+	interval := last.Sub(previous)
+	if interval <= 0 {
+		return last, count
+	}
+	count += int(now.Sub(last) / interval)
+	for back := 2 * interval; ; back *= 2 {
+		from := now.Add(-back)
+		if !from.After(last) {
+			from = last
+		}
+		found := false
+		for t := s.Next(from); !t.IsZero() && !t.After(now); t = s.Next(t) {
+			last, found = t, true
+		}
+		if found || from.Equal(last) {
+			return last, count
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	redisapi "resource-demo/apis/redis"
+	redisv1 "resource-demo/apis/redis/v1"
+	"resource-demo/generated/clientset/versioned"
+	redisinformers "resource-demo/generated/informers/externalversions/redis/v1"
+	redislisters "resource-demo/generated/listers/redis/v1"
+	"strconv"
+	"time"
+)
+
+const (
+	// 和controller创建的StatefulSet使用同一个镜像，command中可以使用redis-cli
+	jobImage = "redis:6.2"
+	// Job名字的后缀是计划时间的unix秒数，Job名字会写到pod的label中，不能超过63个字符
+	maxJobNamePrefix = 52
+
+	// Job的label和annotation
+	InstanceLabel           = "cs.handpay.cn/instance"
+	ScheduledTimeAnnotation = "cs.handpay.cn/scheduled-time"
+)
+
+// 在spec.schedule到达时创建Job执行spec.command，并在status中记录最近一次和下一次执行的时间
+type Scheduler struct {
+	kubeClient  kubernetes.Interface
+	redisClient versioned.Interface
+
+	redisLister redislisters.RedisLister
+	synced      cache.InformerSynced
+	clock       clock.Clock
+
+	queue workqueue.RateLimitingInterface
+}
+
+// 实例化Scheduler，clock用于判断计划时间是否到达，测试时可以使用fake clock
+func NewScheduler(kubeClient kubernetes.Interface, redisClient versioned.Interface, redisInformer redisinformers.RedisInformer, clock clock.Clock) *Scheduler {
+	s := &Scheduler{
+		kubeClient:  kubeClient,
+		redisClient: redisClient,
+		redisLister: redisInformer.Lister(),
+		synced:      redisInformer.Informer().HasSynced,
+		clock:       clock,
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "redis-scheduler"),
+	}
+	redisInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: s.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			s.enqueue(newObj)
+		},
+	})
+	return s
+}
+
+func (s *Scheduler) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	s.queue.Add(key)
+}
+
+// 负责观察和调度，stopCh关闭时返回
+func (s *Scheduler) Run(workers int, stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer s.queue.ShutDown()
+
+	klog.Info("启动 Redis scheduler")
+
+	if !cache.WaitForCacheSync(stopCh, s.synced) {
+		return fmt.Errorf("同步超时了")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(s.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	klog.Info("停止 Redis scheduler")
+	return nil
+}
+
+func (s *Scheduler) runWorker() {
+	for s.processNextItem() {
+	}
+}
+
+func (s *Scheduler) processNextItem() bool {
+	key, shutdown := s.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer s.queue.Done(key)
+
+	requeueAfter, err := s.sync(key.(string))
+	if err != nil {
+		s.handleErr(err, key)
+		return true
+	}
+	s.queue.Forget(key)
+	// 到下一次计划时间时再处理
+	if requeueAfter > 0 {
+		s.queue.AddAfter(key, requeueAfter)
+	}
+	return true
+}
+
+func (s *Scheduler) handleErr(err error, key interface{}) {
+	// 如果出现问题，重试5次
+	if s.queue.NumRequeues(key) < 5 {
+		klog.Infof("调度redis %v 错误: %v", key, err)
+		s.queue.AddRateLimited(key)
+		return
+	}
+
+	s.queue.Forget(key)
+	runtime.HandleError(fmt.Errorf("放弃调度redis %v: %v", key, err))
+}
+
+// 计划时间已到时创建Job，返回距离下一次计划时间的间隔，0表示不会再执行
+func (s *Scheduler) sync(key string) (time.Duration, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return 0, err
+	}
+	redis, err := s.redisLister.Redises(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// Job有ownerReferences，由垃圾回收删除
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if redis.Spec.Schedule == "" || redis.Spec.Command == "" {
+		return 0, nil
+	}
+	schedule, err := redisapi.ParseSchedule(redis.Spec.Schedule)
+	if err != nil {
+		// 重试也不会成功，等spec修改后再处理
+		runtime.HandleError(fmt.Errorf("redis %s: %v", key, err))
+		return 0, nil
+	}
+
+	now := s.clock.Now()
+	// 从上次执行的时间开始计算；从没执行过时，cron从创建时间开始，只执行一次的schedule不限制
+	after := redis.CreationTimestamp.Time
+	if redis.Status.LastScheduleTime != nil {
+		after = redis.Status.LastScheduleTime.Time
+	} else if _, once := schedule.(redisapi.OnceSchedule); once {
+		after = time.Time{}
+	}
+
+	status := redis.Status.DeepCopy()
+	scheduled, count := mostRecent(schedule, after, now)
+	if !scheduled.IsZero() {
+		switch {
+		case count > maxMissedSchedules:
+			runtime.HandleError(fmt.Errorf("Redis %s 错过了超过%d次执行（约%d次），只执行最近的一次 %s，请检查scheduler是否长时间停止或时钟是否偏移",
+				key, maxMissedSchedules, count-1, scheduled.Format(time.RFC3339)))
+		case count > 1:
+			klog.Infof("Redis %s 错过了%d次执行，只执行最近的一次 %s", key, count-1, scheduled.Format(time.RFC3339))
+		}
+		if err := s.createJob(redis, scheduled); err != nil {
+			return 0, err
+		}
+		status.LastScheduleTime = &metav1.Time{Time: scheduled}
+	}
+
+	next := schedule.Next(now)
+	status.NextScheduleTime = nil
+	if !next.IsZero() {
+		status.NextScheduleTime = &metav1.Time{Time: next}
+	}
+	if err := s.updateStatus(redis, status); err != nil {
+		return 0, err
+	}
+	if next.IsZero() {
+		return 0, nil
+	}
+	return next.Sub(now), nil
+}
+
+// Job的名字由Redis的名字和计划时间决定，scheduler重启或status写入失败后不会重复创建
+func (s *Scheduler) createJob(redis *redisv1.Redis, scheduled time.Time) error {
+	job := newJob(redis, scheduled)
+	_, err := s.kubeClient.BatchV1().Jobs(redis.Namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		klog.Infof("Job %s/%s 已经创建过了", job.Namespace, job.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	klog.Infof("创建Job %s/%s，计划时间 %s", job.Namespace, job.Name, scheduled.Format(time.RFC3339))
+	return nil
+}
+
+func jobName(redis *redisv1.Redis, scheduled time.Time) string {
+	prefix := redis.Name
+	if len(prefix) > maxJobNamePrefix {
+		prefix = prefix[:maxJobNamePrefix]
+	}
+	return prefix + "-" + strconv.FormatInt(scheduled.Unix(), 10)
+}
+
+// 在redis镜像中用sh -c执行spec.command，失败不重启
+func newJob(redis *redisv1.Redis, scheduled time.Time) *batchv1.Job {
+	labels := map[string]string{InstanceLabel: redis.Name}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jobName(redis, scheduled),
+			Namespace:       redis.Namespace,
+			Labels:          labels,
+			Annotations:     map[string]string{ScheduledTimeAnnotation: scheduled.Format(time.RFC3339)},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(redis, redisv1.SchemeGroupVersion.WithKind("Redis"))},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "command",
+						Image:   jobImage,
+						Command: []string{"sh", "-c", redis.Spec.Command},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// 通过status子资源写入lastScheduleTime和nextScheduleTime，没有变化时不写
+func (s *Scheduler) updateStatus(redis *redisv1.Redis, status *redisv1.RedisStatus) error {
+	if equality.Semantic.DeepEqual(&redis.Status, status) {
+		return nil
+	}
+	// lister中的对象是共享的，修改前先复制
+	redis = redis.DeepCopy()
+	redis.Status = *status
+	_, err := s.redisClient.RedisV1().Redises(redis.Namespace).UpdateStatus(context.TODO(), redis, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,336 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	redisapi "resource-demo/apis/redis"
+	redisv1 "resource-demo/apis/redis/v1"
+	"resource-demo/generated/clientset/versioned/fake"
+	"resource-demo/generated/informers/externalversions"
+	"testing"
+	"time"
+)
+
+var created = time.Date(2022, 11, 17, 10, 0, 0, 0, time.UTC)
+
+func newRedis(schedule string) *redisv1.Redis {
+	return &redisv1.Redis{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "redis-uid", CreationTimestamp: metav1.NewTime(created)},
+		Spec:       redisv1.RedisSpec{Schedule: schedule, Command: "echo redis crd2!"},
+	}
+}
+
+type fixture struct {
+	t           *testing.T
+	kubeClient  *kubefake.Clientset
+	redisClient *fake.Clientset
+	informers   externalversions.SharedInformerFactory
+	clock       *clocktesting.FakeClock
+	scheduler   *Scheduler
+}
+
+// Redis同时写入fake clientset和informer的缓存，sync直接读缓存，不需要启动informer
+func newFixture(t *testing.T, redis *redisv1.Redis, now time.Time, kubeObjects ...runtime.Object) *fixture {
+	kubeClient := kubefake.NewSimpleClientset(kubeObjects...)
+	redisClient := fake.NewSimpleClientset()
+	// NewSimpleClientset会把Redis的复数猜成redises，这里用正确的resource写入tracker
+	if err := redisClient.Tracker().Create(redisv1.SchemeGroupVersion.WithResource("redis"), redis, redis.Namespace); err != nil {
+		t.Fatal(err)
+	}
+	informers := externalversions.NewSharedInformerFactory(redisClient, 0)
+	redisInformer := informers.Redis().V1().Redises()
+	if err := redisInformer.Informer().GetIndexer().Add(redis); err != nil {
+		t.Fatal(err)
+	}
+	clock := clocktesting.NewFakeClock(now)
+	return &fixture{
+		t:           t,
+		kubeClient:  kubeClient,
+		redisClient: redisClient,
+		informers:   informers,
+		clock:       clock,
+		scheduler:   NewScheduler(kubeClient, redisClient, redisInformer, clock),
+	}
+}
+
+func (f *fixture) sync() time.Duration {
+	requeueAfter, err := f.scheduler.sync("default/test")
+	if err != nil {
+		f.t.Fatalf("sync: %v", err)
+	}
+	return requeueAfter
+}
+
+// 把status写入后的Redis放回informer缓存，模拟收到watch事件。
+// 经过一次JSON编解码，和apiserver一样时间只保留到秒
+func (f *fixture) refresh() *redisv1.Redis {
+	stored, err := f.redisClient.RedisV1().Redises("default").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	redis := &redisv1.Redis{}
+	if err := json.Unmarshal(data, redis); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := f.informers.Redis().V1().Redises().Informer().GetIndexer().Update(redis); err != nil {
+		f.t.Fatal(err)
+	}
+	return redis
+}
+
+func (f *fixture) jobs() []string {
+	list, err := f.kubeClient.BatchV1().Jobs("default").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	var names []string
+	for _, job := range list.Items {
+		names = append(names, job.Name)
+	}
+	return names
+}
+
+func (f *fixture) statusUpdates() int {
+	count := 0
+	for _, action := range f.redisClient.Actions() {
+		if action.Matches("update", "redis") && action.GetSubresource() == "status" {
+			count++
+		}
+	}
+	return count
+}
+
+func checkTime(t *testing.T, field string, got *metav1.Time, want time.Time) {
+	t.Helper()
+	if want.IsZero() {
+		if got != nil {
+			t.Errorf("%s = %v, want nil", field, got)
+		}
+		return
+	}
+	if got == nil || !got.Time.Equal(want) {
+		t.Errorf("%s = %v, want %v", field, got, want)
+	}
+}
+
+func TestSyncOnce(t *testing.T) {
+	at := time.Date(2022, 11, 17, 10, 12, 0, 0, time.UTC)
+
+	// 计划时间还没到
+	f := newFixture(t, newRedis("2022-11-17T10:12:00Z"), at.Add(-time.Minute))
+	if requeueAfter := f.sync(); requeueAfter != time.Minute {
+		t.Errorf("requeueAfter = %v, want 1m", requeueAfter)
+	}
+	if jobs := f.jobs(); len(jobs) != 0 {
+		t.Errorf("unexpected jobs %v", jobs)
+	}
+	redis := f.refresh()
+	checkTime(t, "nextScheduleTime", redis.Status.NextScheduleTime, at)
+
+	// 到达计划时间后执行，之后不再执行
+	f.clock.SetTime(at.Add(time.Second))
+	if requeueAfter := f.sync(); requeueAfter != 0 {
+		t.Errorf("requeueAfter = %v, want 0", requeueAfter)
+	}
+	redis = f.refresh()
+	checkTime(t, "lastScheduleTime", redis.Status.LastScheduleTime, at)
+	checkTime(t, "nextScheduleTime", redis.Status.NextScheduleTime, time.Time{})
+
+	f.clock.SetTime(at.Add(time.Hour))
+	f.sync()
+	if jobs := f.jobs(); len(jobs) != 1 || jobs[0] != "test-1668679920" {
+		t.Errorf("jobs = %v, want [test-1668679920]", jobs)
+	}
+
+	job, err := f.kubeClient.BatchV1().Jobs("default").Get(context.TODO(), "test-1668679920", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if len(container.Command) != 3 || container.Command[2] != "echo redis crd2!" {
+		t.Errorf("command = %v", container.Command)
+	}
+	if !metav1.IsControlledBy(job, redis) || job.Annotations[ScheduledTimeAnnotation] != "2022-11-17T10:12:00Z" {
+		t.Errorf("unexpected job metadata %+v", job.ObjectMeta)
+	}
+}
+
+// 带小数秒的计划时间按整秒执行，status写入后再次同步时不会重复写入
+func TestSyncOnceFractionalSeconds(t *testing.T) {
+	at := time.Date(2022, 11, 17, 10, 12, 0, 0, time.UTC)
+	f := newFixture(t, newRedis("2022-11-17T10:12:00.5Z"), at.Add(-time.Minute))
+	f.sync()
+	checkTime(t, "nextScheduleTime", f.refresh().Status.NextScheduleTime, at)
+	f.sync()
+	if n := f.statusUpdates(); n != 1 {
+		t.Errorf("status updated %d times before the schedule, want 1", n)
+	}
+
+	f.redisClient.ClearActions()
+	f.clock.SetTime(at.Add(time.Second))
+	f.sync()
+	checkTime(t, "lastScheduleTime", f.refresh().Status.LastScheduleTime, at)
+	f.sync()
+	f.refresh()
+	f.sync()
+	if n := f.statusUpdates(); n != 1 {
+		t.Errorf("status updated %d times after the schedule, want 1", n)
+	}
+	if jobs := f.jobs(); len(jobs) != 1 || jobs[0] != "test-1668679920" {
+		t.Errorf("jobs = %v, want [test-1668679920]", jobs)
+	}
+}
+
+// Redis在计划时间之后才创建，仍然执行一次
+func TestSyncOnceCreatedLate(t *testing.T) {
+	redis := newRedis("2022-11-17T09:00:00Z")
+	f := newFixture(t, redis, created.Add(time.Minute))
+	f.sync()
+	if jobs := f.jobs(); len(jobs) != 1 {
+		t.Errorf("jobs = %v, want one job", jobs)
+	}
+}
+
+func TestSyncCron(t *testing.T) {
+	// 创建后错过了10:05，只执行最近的10:10
+	f := newFixture(t, newRedis("*/5 * * * *"), created.Add(12*time.Minute))
+	if requeueAfter := f.sync(); requeueAfter != 3*time.Minute {
+		t.Errorf("requeueAfter = %v, want 3m", requeueAfter)
+	}
+	redis := f.refresh()
+	checkTime(t, "lastScheduleTime", redis.Status.LastScheduleTime, created.Add(10*time.Minute))
+	checkTime(t, "nextScheduleTime", redis.Status.NextScheduleTime, created.Add(15*time.Minute))
+
+	// 下一次计划时间之前不会重复执行，status没有变化时不写
+	f.redisClient.ClearActions()
+	f.clock.SetTime(created.Add(14 * time.Minute))
+	f.sync()
+	if n := f.statusUpdates(); n != 0 {
+		t.Errorf("status updated %d times, want 0", n)
+	}
+
+	f.clock.SetTime(created.Add(15*time.Minute + 30*time.Second))
+	f.sync()
+	f.refresh()
+	if jobs := f.jobs(); len(jobs) != 2 || jobs[0] != "test-1668679800" || jobs[1] != "test-1668680100" {
+		t.Errorf("jobs = %v", jobs)
+	}
+}
+
+// Job已经创建但status没有写入（例如scheduler重启），不重复创建也不报错
+func TestSyncJobAlreadyExists(t *testing.T) {
+	redis := newRedis("2022-11-17T10:12:00Z")
+	existing := newJob(redis, time.Date(2022, 11, 17, 10, 12, 0, 0, time.UTC))
+	f := newFixture(t, redis, created.Add(time.Hour), existing)
+	f.sync()
+
+	creates := 0
+	for _, action := range f.kubeClient.Actions() {
+		if action.Matches("create", "jobs") {
+			creates++
+		}
+	}
+	if jobs := f.jobs(); creates != 1 || len(jobs) != 1 {
+		t.Errorf("creates = %d, jobs = %v", creates, jobs)
+	}
+	checkTime(t, "lastScheduleTime", f.refresh().Status.LastScheduleTime, time.Date(2022, 11, 17, 10, 12, 0, 0, time.UTC))
+}
+
+func TestSyncJobError(t *testing.T) {
+	f := newFixture(t, newRedis("2022-11-17T10:12:00Z"), created.Add(time.Hour))
+	f.kubeClient.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, context.DeadlineExceeded
+	})
+	if _, err := f.scheduler.sync("default/test"); err == nil {
+		t.Fatal("want error")
+	}
+	// 没有创建成功时不记录lastScheduleTime，重试时再次创建
+	if n := f.statusUpdates(); n != 0 {
+		t.Errorf("status updated %d times, want 0", n)
+	}
+}
+
+func TestSyncSkipped(t *testing.T) {
+	tests := []struct {
+		name  string
+		redis *redisv1.Redis
+	}{
+		{name: "no schedule", redis: newRedis("")},
+		{name: "invalid schedule", redis: newRedis("tomorrow")},
+		{name: "no command", redis: func() *redisv1.Redis {
+			redis := newRedis("* * * * *")
+			redis.Spec.Command = ""
+			return redis
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.redis, created.Add(time.Hour))
+			if requeueAfter := f.sync(); requeueAfter != 0 {
+				t.Errorf("requeueAfter = %v", requeueAfter)
+			}
+			if actions := f.kubeClient.Actions(); len(actions) != 0 {
+				t.Errorf("unexpected actions %v", actions)
+			}
+		})
+	}
+
+	f := newFixture(t, newRedis("* * * * *"), created)
+	if err := f.redisClient.Tracker().Delete(redisv1.SchemeGroupVersion.WithResource("redis"), "default", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.informers.Redis().V1().Redises().Informer().GetIndexer().Delete(newRedis("")); err != nil {
+		t.Fatal(err)
+	}
+	f.sync()
+}
+
+func TestMostRecent(t *testing.T) {
+	everyMinute, err := redisapi.ParseSchedule("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekdays, err := redisapi.ParseSchedule("30 6-16/4 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		schedule redisapi.Schedule
+		after    time.Time
+		now      time.Time
+		want     time.Time
+		count    int
+	}{
+		{name: "none", schedule: everyMinute, after: created, now: created.Add(30 * time.Second)},
+		{name: "few missed", schedule: everyMinute, after: created, now: created.Add(5*time.Minute + time.Second), want: created.Add(5 * time.Minute), count: 5},
+		{name: "exactly the limit", schedule: everyMinute, after: created, now: created.Add(maxMissedSchedules * time.Minute), want: created.Add(maxMissedSchedules * time.Minute), count: maxMissedSchedules},
+		// scheduler停了一年，不会逐个计算50多万次
+		{name: "a year missed", schedule: everyMinute, after: created, now: created.AddDate(1, 0, 0).Add(30 * time.Second), want: created.AddDate(1, 0, 0), count: 365 * 24 * 60},
+		// 间隔不均匀时估算的个数不准，但最近的一次是准确的
+		{name: "irregular", schedule: weekdays, after: created, now: time.Date(2023, 11, 19, 12, 0, 0, 0, time.UTC), want: time.Date(2023, 11, 17, 14, 30, 0, 0, time.UTC), count: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := mostRecent(tt.schedule, tt.after, tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("mostRecent = %v, want %v", got, tt.want)
+			}
+			if tt.count >= 0 && count != tt.count {
+				t.Errorf("count = %d, want %d", count, tt.count)
+			}
+			if tt.count < 0 && count <= maxMissedSchedules {
+				t.Errorf("count = %d, want more than %d", count, maxMissedSchedules)
+			}
+		})
+	}
+}
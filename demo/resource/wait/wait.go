@@ -0,0 +1,186 @@
+package wait
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+	"strings"
+)
+
+// 超时后返回的错误，可以用errors.Is判断
+var ErrTimeout = errors.New("timed out waiting for the condition")
+
+// 判断对象是否满足条件，obj为nil表示对象不存在
+type Condition func(obj *unstructured.Unstructured) (bool, error)
+
+// 等待name指定的对象满足cond，ctx超时返回ErrTimeout。
+// 先get当前状态，不满足时从get到的resourceVersion开始watch；连接断开或resourceVersion过期时重新get
+func For(ctx context.Context, client dynamic.ResourceInterface, name string, cond Condition) error {
+	for {
+		obj, err := client.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			obj, err = nil, nil
+		}
+		if err != nil {
+			return timeout(ctx, name, err)
+		}
+		if done, err := cond(obj); err != nil || done {
+			return err
+		}
+
+		// 对象不存在时resourceVersion为空，watch会先返回当前已有的对象
+		resourceVersion := ""
+		if obj != nil {
+			resourceVersion = obj.GetResourceVersion()
+		}
+		retry, err := watchUntil(ctx, client, name, resourceVersion, cond)
+		if !retry {
+			return timeout(ctx, name, err)
+		}
+	}
+}
+
+// 返回true表示需要重新get再watch
+func watchUntil(ctx context.Context, client dynamic.ResourceInterface, name, resourceVersion string, cond Condition) (bool, error) {
+	w, err := client.Watch(ctx, metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+		ResourceVersion: resourceVersion,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return true, nil
+			}
+			var obj *unstructured.Unstructured
+			switch event.Type {
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				return apierrors.IsResourceExpired(err) || apierrors.IsGone(err), err
+			case watch.Bookmark:
+				continue
+			case watch.Deleted:
+			default:
+				if obj, ok = event.Object.(*unstructured.Unstructured); !ok {
+					return false, fmt.Errorf("unexpected object %T in %s event", event.Object, event.Type)
+				}
+			}
+			if done, err := cond(obj); err != nil || done {
+				return false, err
+			}
+		}
+	}
+}
+
+// ctx超时的错误统一成ErrTimeout
+func timeout(ctx context.Context, name string, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s: %w", name, ErrTimeout)
+	}
+	return err
+}
+
+// 对象已经被删除
+func Deleted(obj *unstructured.Unstructured) (bool, error) {
+	return obj == nil, nil
+}
+
+// 对象已经存在
+func Exists(obj *unstructured.Unstructured) (bool, error) {
+	return obj != nil, nil
+}
+
+// Pod的Ready condition为True
+func PodReady(obj *unstructured.Unstructured) (bool, error) {
+	if obj == nil {
+		return false, nil
+	}
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Ready" {
+			return condition["status"] == "True", nil
+		}
+	}
+	return false, nil
+}
+
+// Deployment的rollout已完成，判断方式和kubectl rollout status一致：
+// 控制器已处理最新的generation，所有副本都已更新、可用，旧副本已经退出
+func DeploymentComplete(obj *unstructured.Unstructured) (bool, error) {
+	if obj == nil {
+		return false, nil
+	}
+	generation := obj.GetGeneration()
+	observed, _, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if err != nil || observed < generation {
+		return false, err
+	}
+
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		replicas = 1
+	}
+	var status [3]int64
+	for i, field := range []string{"replicas", "updatedReplicas", "availableReplicas"} {
+		if status[i], _, err = unstructured.NestedInt64(obj.Object, "status", field); err != nil {
+			return false, err
+		}
+	}
+	total, updated, available := status[0], status[1], status[2]
+	return updated >= replicas && total <= updated && available >= updated, nil
+}
+
+// 解析 {.status.phase}=Running 或 .status.phase=Running，JSONPath的结果等于value时满足条件
+func JSONPath(spec string) (Condition, error) {
+	var expr, value string
+	if strings.HasPrefix(spec, "{") {
+		end := strings.LastIndex(spec, "}")
+		if end < 0 || !strings.HasPrefix(spec[end+1:], "=") {
+			return nil, fmt.Errorf("invalid condition %q, expected {<jsonpath>}=<value>", spec)
+		}
+		expr, value = spec[:end+1], spec[end+2:]
+	} else {
+		i := strings.LastIndex(spec, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid condition %q, expected <jsonpath>=<value>", spec)
+		}
+		expr, value = "{"+spec[:i]+"}", spec[i+1:]
+	}
+
+	parser := jsonpath.New("condition").AllowMissingKeys(true)
+	if err := parser.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q: %v", expr, err)
+	}
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		if obj == nil {
+			return false, nil
+		}
+		var buf bytes.Buffer
+		if err := parser.Execute(&buf, obj.Object); err != nil {
+			return false, err
+		}
+		return buf.String() == value, nil
+	}, nil
+}
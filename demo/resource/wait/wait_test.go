@@ -0,0 +1,152 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+var podRes = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func pod(ready string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "demo", "namespace": "default", "resourceVersion": "1"},
+		"status": map[string]interface{}{
+			"phase":      "Running",
+			"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+		},
+	}}
+	return obj
+}
+
+func deployment(generation, observed, replicas, total, updated, available int64) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "demo", "generation": generation},
+		"spec":       map[string]interface{}{"replicas": replicas},
+		"status": map[string]interface{}{
+			"observedGeneration": observed,
+			"replicas":           total,
+			"updatedReplicas":    updated,
+			"availableReplicas":  available,
+		},
+	}}
+	return obj
+}
+
+func TestConditions(t *testing.T) {
+	phase, err := JSONPath("{.status.phase}=Running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready, err := JSONPath(`.status.conditions[?(@.type=="Ready")].status=True`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cond Condition
+		obj  *unstructured.Unstructured
+		want bool
+	}{
+		{name: "pod ready", cond: PodReady, obj: pod("True"), want: true},
+		{name: "pod not ready", cond: PodReady, obj: pod("False")},
+		{name: "pod missing", cond: PodReady},
+		{name: "rollout complete", cond: DeploymentComplete, obj: deployment(2, 2, 3, 3, 3, 3), want: true},
+		{name: "generation not observed", cond: DeploymentComplete, obj: deployment(3, 2, 3, 3, 3, 3)},
+		{name: "replicas not updated", cond: DeploymentComplete, obj: deployment(2, 2, 3, 3, 2, 2)},
+		{name: "old replicas terminating", cond: DeploymentComplete, obj: deployment(2, 2, 3, 4, 3, 3)},
+		{name: "replicas not available", cond: DeploymentComplete, obj: deployment(2, 2, 3, 3, 3, 2)},
+		{name: "deleted", cond: Deleted, want: true},
+		{name: "not deleted", cond: Deleted, obj: pod("True")},
+		{name: "jsonpath", cond: phase, obj: pod("False"), want: true},
+		{name: "jsonpath filter", cond: ready, obj: pod("True"), want: true},
+		{name: "jsonpath filter mismatch", cond: ready, obj: pod("False")},
+		{name: "jsonpath missing object", cond: phase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cond(tt.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, spec := range []string{"phase", "{.status.phase}", "{.status.phase=Running", "{.status[}=x"} {
+		if _, err := JSONPath(spec); err == nil {
+			t.Errorf("JSONPath(%q): want error", spec)
+		}
+	}
+}
+
+// 返回fake客户端，watch依次返回watchers
+func newClient(watchers ...*watch.FakeWatcher) *fakedynamic.FakeDynamicClient {
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), pod("False"))
+	client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watchers[0]
+		watchers = watchers[1:]
+		return true, w, nil
+	})
+	return client
+}
+
+func TestFor(t *testing.T) {
+	first, second := watch.NewFake(), watch.NewFake()
+	client := newClient(first, second)
+	go func() {
+		first.Modify(pod("False"))
+		// resourceVersion过期后重新get，再从新的resourceVersion开始watch
+		expired := apierrors.NewResourceExpired("too old resource version: 1")
+		first.Error(&expired.ErrStatus)
+		second.Modify(pod("True"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := For(ctx, client.Resource(podRes).Namespace("default"), "demo", PodReady); err != nil {
+		t.Fatalf("For: %v", err)
+	}
+}
+
+func TestForDeleted(t *testing.T) {
+	w := watch.NewFake()
+	client := newClient(w)
+	go w.Delete(pod("False"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := For(ctx, client.Resource(podRes).Namespace("default"), "demo", Deleted); err != nil {
+		t.Fatalf("For: %v", err)
+	}
+
+	// 对象已经不存在时不需要watch
+	if err := For(ctx, client.Resource(podRes).Namespace("default"), "missing", Deleted); err != nil {
+		t.Fatalf("For missing object: %v", err)
+	}
+}
+
+func TestForTimeout(t *testing.T) {
+	client := newClient(watch.NewFake())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := For(ctx, client.Resource(podRes).Namespace("default"), "demo", PodReady)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("err = %v, want ErrTimeout", err)
+	}
+}
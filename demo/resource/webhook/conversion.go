@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"net/http"
+	redisv1 "resource-demo/apis/redis/v1"
+	redisv2 "resource-demo/apis/redis/v2"
+)
+
+// 转换webhook的路径，和crd.yaml中spec.conversion.webhook.clientConfig.service.path一致
+const ConvertPath = "/convert"
+
+// 处理ConversionReview v1，把每个对象转换成desiredAPIVersion
+func convertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); !isJSON(contentType) {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, expected application/json", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid ConversionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "ConversionReview without request", http.StatusBadRequest)
+		return
+	}
+
+	response := &apiextensionsv1.ConversionResponse{
+		UID:    review.Request.UID,
+		Result: metav1.Status{Status: metav1.StatusSuccess},
+	}
+	for _, object := range review.Request.Objects {
+		converted, err := convert(object.Raw, review.Request.DesiredAPIVersion)
+		if err != nil {
+			// 有一个对象失败时整个请求失败，apiserver不接受部分转换的结果
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			break
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+	review.Response = response
+	review.Request = nil
+	review.SetGroupVersionKind(apiextensionsv1.SchemeGroupVersion.WithKind("ConversionReview"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.Errorf("写入ConversionReview失败: %v", err)
+	}
+}
+
+// 在v1、v2之间转换一个Redis，版本相同时原样返回
+func convert(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("invalid object: %v", err)
+	}
+	if typeMeta.Kind != "Redis" {
+		return nil, fmt.Errorf("unexpected kind %q, expected Redis", typeMeta.Kind)
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	var out interface{}
+	switch {
+	case typeMeta.APIVersion == redisv1.SchemeGroupVersion.String() && desiredAPIVersion == redisv2.SchemeGroupVersion.String():
+		in := &redisv1.Redis{}
+		if err := json.Unmarshal(raw, in); err != nil {
+			return nil, fmt.Errorf("invalid %s Redis: %v", typeMeta.APIVersion, err)
+		}
+		converted, err := redisv2.FromV1(in)
+		if err != nil {
+			return nil, err
+		}
+		out = converted
+	case typeMeta.APIVersion == redisv2.SchemeGroupVersion.String() && desiredAPIVersion == redisv1.SchemeGroupVersion.String():
+		in := &redisv2.Redis{}
+		if err := json.Unmarshal(raw, in); err != nil {
+			return nil, fmt.Errorf("invalid %s Redis: %v", typeMeta.APIVersion, err)
+		}
+		converted, err := redisv2.ToV1(in)
+		if err != nil {
+			return nil, err
+		}
+		out = converted
+	default:
+		return nil, fmt.Errorf("unsupported conversion from %s to %s", typeMeta.APIVersion, desiredAPIVersion)
+	}
+	return json.Marshal(out)
+}
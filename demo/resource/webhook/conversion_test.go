@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	"net/http/httptest"
+	redisv1 "resource-demo/apis/redis/v1"
+	redisv2 "resource-demo/apis/redis/v2"
+	"strings"
+	"testing"
+)
+
+func convertReview(t *testing.T, server *httptest.Server, desired string, objects ...string) *apiextensionsv1.ConversionResponse {
+	t.Helper()
+	request := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request:  &apiextensionsv1.ConversionRequest{UID: "uid-1", DesiredAPIVersion: desired},
+	}
+	for _, object := range objects {
+		request.Request.Objects = append(request.Request.Objects, runtime.RawExtension{Raw: []byte(object)})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.Client().Post(server.URL+ConvertPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code = %d", resp.StatusCode)
+	}
+
+	result := &apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Kind != "ConversionReview" || result.Response == nil || result.Response.UID != "uid-1" {
+		t.Fatalf("unexpected review %+v", result)
+	}
+	return result.Response
+}
+
+func TestConvert(t *testing.T) {
+	server := httptest.NewTLSServer(NewHandler())
+	defer server.Close()
+
+	v1Object := `{"apiVersion":"cs.handpay.cn/v1","kind":"Redis","metadata":{"name":"test","namespace":"default"},"spec":{"command":"echo redis crd2!","replicas":2,"phase":"Running"}}`
+	resp := convertReview(t, server, "cs.handpay.cn/v2", v1Object, v1Object)
+	if resp.Result.Status != metav1.StatusSuccess || len(resp.ConvertedObjects) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	v2 := &redisv2.Redis{}
+	if err := json.Unmarshal(resp.ConvertedObjects[0].Raw, v2); err != nil {
+		t.Fatal(err)
+	}
+	if v2.APIVersion != "cs.handpay.cn/v2" || v2.Kind != "Redis" || strings.Join(v2.Spec.Command, "|") != "echo|redis|crd2!" || v2.Spec.Phase != redisv2.PhaseRunning {
+		t.Errorf("unexpected v2 object %s", resp.ConvertedObjects[0].Raw)
+	}
+
+	// 转换回v1
+	resp = convertReview(t, server, "cs.handpay.cn/v1", string(resp.ConvertedObjects[0].Raw))
+	if resp.Result.Status != metav1.StatusSuccess || len(resp.ConvertedObjects) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	v1 := &redisv1.Redis{}
+	if err := json.Unmarshal(resp.ConvertedObjects[0].Raw, v1); err != nil {
+		t.Fatal(err)
+	}
+	if v1.APIVersion != "cs.handpay.cn/v1" || v1.Spec.Command != "echo redis crd2!" || *v1.Spec.Replicas != 2 {
+		t.Errorf("unexpected v1 object %s", resp.ConvertedObjects[0].Raw)
+	}
+
+	// 版本相同时原样返回
+	resp = convertReview(t, server, "cs.handpay.cn/v1", v1Object)
+	if len(resp.ConvertedObjects) != 1 || string(resp.ConvertedObjects[0].Raw) != v1Object {
+		t.Errorf("same version should not be changed: %+v", resp)
+	}
+}
+
+func TestConvertFailure(t *testing.T) {
+	server := httptest.NewServer(NewHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		desired string
+		object  string
+	}{
+		{name: "unknown version", desired: "cs.handpay.cn/v3", object: `{"apiVersion":"cs.handpay.cn/v1","kind":"Redis"}`},
+		{name: "wrong kind", desired: "cs.handpay.cn/v2", object: `{"apiVersion":"v1","kind":"Pod"}`},
+		{name: "invalid spec", desired: "cs.handpay.cn/v2", object: `{"apiVersion":"cs.handpay.cn/v1","kind":"Redis","spec":{"replicas":"two"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid := `{"apiVersion":"cs.handpay.cn/v1","kind":"Redis","metadata":{"name":"ok"}}`
+			resp := convertReview(t, server, tt.desired, valid, tt.object)
+			if resp.Result.Status != metav1.StatusFailure || resp.Result.Message == "" {
+				t.Errorf("want failure, got %+v", resp.Result)
+			}
+			if len(resp.ConvertedObjects) != 0 {
+				t.Errorf("failed review should not return objects: %d", len(resp.ConvertedObjects))
+			}
+		})
+	}
+}
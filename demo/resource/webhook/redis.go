@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	redisapi "resource-demo/apis/redis"
+	redisv1 "resource-demo/apis/redis/v1"
+)
+
+// 和controller一致，spec.replicas未设置时为1个副本
+const defaultReplicas = 1
+
+var validPhases = []string{redisv1.PhasePending, redisv1.PhaseRunning, redisv1.PhaseFailed}
+
+// JSONPatch（RFC 6902）的一个操作
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// 校验spec：replicas不能为负数，schedule必须是RFC3339时间或cron表达式，phase只能是已知的取值
+func validateRedis(redis *redisv1.Redis) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := redis.Spec
+
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *spec.Replicas, "must be greater than or equal to 0"))
+	}
+	if spec.Schedule != "" {
+		if _, err := redisapi.ParseSchedule(spec.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("schedule"), spec.Schedule, "must be an RFC3339 time or a cron expression, e.g. 2022-11-17T10:12:00Z or */5 * * * *"))
+		}
+	}
+	if spec.Phase != "" && !contains(validPhases, spec.Phase) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("phase"), spec.Phase, validPhases))
+	}
+	return allErrs
+}
+
+// 为没有设置的replicas、phase生成补丁。raw是请求中的原始对象，没有spec时整体添加
+func defaultRedis(raw []byte, redis *redisv1.Redis) ([]patchOperation, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	defaults := map[string]interface{}{}
+	if redis.Spec.Replicas == nil {
+		defaults["replicas"] = defaultReplicas
+	}
+	if redis.Spec.Phase == "" {
+		defaults["phase"] = redisv1.PhasePending
+	}
+	if len(defaults) == 0 {
+		return nil, nil
+	}
+	if spec, ok := object["spec"]; !ok || string(spec) == "null" {
+		return []patchOperation{{Op: "add", Path: "/spec", Value: defaults}}, nil
+	}
+
+	var patches []patchOperation
+	for _, key := range []string{"replicas", "phase"} {
+		if value, ok := defaults[key]; ok {
+			patches = append(patches, patchOperation{Op: "add", Path: fmt.Sprintf("/spec/%s", key), Value: value})
+		}
+	}
+	return patches, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"mime"
+	"net/http"
+	redisv1 "resource-demo/apis/redis/v1"
+	"time"
+)
+
+// webhook的路径，和ValidatingWebhookConfiguration、MutatingWebhookConfiguration中的service.path一致
+const (
+	ValidatePath = "/validate-redis"
+	MutatePath   = "/mutate-redis"
+)
+
+// 处理AdmissionReview的函数，返回的response不需要设置UID
+type admitFunc func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// 校验、默认值和版本转换webhook的路由
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(ValidatePath, admitHandler(validate))
+	mux.Handle(MutatePath, admitHandler(mutate))
+	mux.HandleFunc(ConvertPath, convertHandler)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+// 使用certFile、keyFile提供HTTPS服务，apiserver只会通过TLS调用webhook
+func ListenAndServeTLS(addr, certFile, keyFile string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           NewHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	klog.Infof("Redis webhook 监听 %s", addr)
+	return server.ListenAndServeTLS(certFile, keyFile)
+}
+
+// Content-Type是否为application/json，忽略charset等参数，例如 application/json; charset=utf-8
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// 解析AdmissionReview v1，调用admit，把response写回同一个AdmissionReview
+func admitHandler(admit admitFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if contentType := r.Header.Get("Content-Type"); !isJSON(contentType) {
+			http.Error(w, fmt.Sprintf("unsupported content type %q, expected application/json", contentType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+			http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "AdmissionReview without request", http.StatusBadRequest)
+			return
+		}
+
+		response := admit(review.Request)
+		response.UID = review.Request.UID
+		review.Response = response
+		review.Request = nil
+		review.SetGroupVersionKind(admissionv1.SchemeGroupVersion.WithKind("AdmissionReview"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			klog.Errorf("写入AdmissionReview失败: %v", err)
+		}
+	}
+}
+
+// 解析请求中的Redis，delete时对象在OldObject中，这里只处理create、update
+func decodeRedis(request *admissionv1.AdmissionRequest) (*redisv1.Redis, error) {
+	gvk := redisv1.SchemeGroupVersion.WithKind("Redis")
+	if request.Kind.Group != gvk.Group || request.Kind.Kind != gvk.Kind {
+		return nil, fmt.Errorf("unexpected kind %s, expected %s", request.Kind, gvk.GroupKind())
+	}
+	redis := &redisv1.Redis{}
+	if err := json.Unmarshal(request.Object.Raw, redis); err != nil {
+		return nil, fmt.Errorf("invalid Redis: %v", err)
+	}
+	return redis, nil
+}
+
+func validate(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	redis, err := decodeRedis(request)
+	if err != nil {
+		return deny(apierrors.NewBadRequest(err.Error()))
+	}
+	if allErrs := validateRedis(redis); len(allErrs) > 0 {
+		return deny(apierrors.NewInvalid(redisv1.SchemeGroupVersion.WithKind("Redis").GroupKind(), redis.Name, allErrs))
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func mutate(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	redis, err := decodeRedis(request)
+	if err != nil {
+		return deny(apierrors.NewBadRequest(err.Error()))
+	}
+	patches, err := defaultRedis(request.Object.Raw, redis)
+	if err != nil {
+		return deny(apierrors.NewBadRequest(err.Error()))
+	}
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+	if len(patches) == 0 {
+		return response
+	}
+	patch, err := json.Marshal(patches)
+	if err != nil {
+		return deny(apierrors.NewInternalError(err))
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	response.Patch = patch
+	response.PatchType = &patchType
+	return response
+}
+
+// 拒绝请求，apiserver会把status原样返回给客户端
+func deny(err *apierrors.StatusError) *admissionv1.AdmissionResponse {
+	status := err.Status()
+	return &admissionv1.AdmissionResponse{Allowed: false, Result: &status}
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	jsonpatch "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	redisv1 "resource-demo/apis/redis/v1"
+	"strings"
+	"testing"
+)
+
+var redisKind = metav1.GroupVersionKind{Group: "cs.handpay.cn", Version: "v1", Kind: "Redis"}
+
+// 发送AdmissionReview并解析返回的AdmissionReview
+func review(t *testing.T, server *httptest.Server, path string, kind metav1.GroupVersionKind, object string) *admissionv1.AdmissionResponse {
+	t.Helper()
+	request := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("uid-1"),
+			Kind:      kind,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(object)},
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.Client().Post(server.URL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code = %d", resp.StatusCode)
+	}
+
+	result := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Kind != "AdmissionReview" || result.APIVersion != "admission.k8s.io/v1" {
+		t.Errorf("response is %s %s", result.APIVersion, result.Kind)
+	}
+	if result.Response == nil || result.Response.UID != "uid-1" {
+		t.Fatalf("response without uid: %+v", result.Response)
+	}
+	return result.Response
+}
+
+func TestValidate(t *testing.T) {
+	server := httptest.NewTLSServer(NewHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		object  string
+		allowed bool
+		causes  []string
+	}{
+		{
+			name:    "valid",
+			object:  `{"metadata":{"name":"test"},"spec":{"schedule":"2022-11-17T10:12:00Z","replicas":2,"phase":"Running"}}`,
+			allowed: true,
+		},
+		{
+			name:    "cron schedule",
+			object:  `{"metadata":{"name":"test"},"spec":{"schedule":"*/5 * * * *","command":"redis-cli ping"}}`,
+			allowed: true,
+		},
+		{
+			name:    "empty spec",
+			object:  `{"metadata":{"name":"test"}}`,
+			allowed: true,
+		},
+		{
+			name:   "negative replicas",
+			object: `{"metadata":{"name":"test"},"spec":{"replicas":-3}}`,
+			causes: []string{"spec.replicas"},
+		},
+		{
+			name:   "all invalid",
+			object: `{"metadata":{"name":"test"},"spec":{"schedule":"tomorrow","replicas":-1,"phase":"Stopped"}}`,
+			causes: []string{"spec.replicas", "spec.schedule", "spec.phase"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := review(t, server, ValidatePath, redisKind, tt.object)
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("allowed = %v, want %v: %+v", resp.Allowed, tt.allowed, resp.Result)
+			}
+			if tt.allowed {
+				return
+			}
+			if resp.Result.Reason != metav1.StatusReasonInvalid || resp.Result.Code != http.StatusUnprocessableEntity {
+				t.Errorf("result = %s %d, want Invalid 422", resp.Result.Reason, resp.Result.Code)
+			}
+			var fields []string
+			for _, cause := range resp.Result.Details.Causes {
+				fields = append(fields, cause.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.causes, ",") {
+				t.Errorf("causes = %v, want %v", fields, tt.causes)
+			}
+		})
+	}
+}
+
+func TestMutate(t *testing.T) {
+	server := httptest.NewServer(NewHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		object   string
+		patched  bool
+		replicas int32
+		phase    string
+	}{
+		{name: "defaults", object: `{"metadata":{"name":"test"},"spec":{"command":"echo"}}`, patched: true, replicas: 1, phase: redisv1.PhasePending},
+		{name: "no spec", object: `{"metadata":{"name":"test"}}`, patched: true, replicas: 1, phase: redisv1.PhasePending},
+		{name: "only phase", object: `{"metadata":{"name":"test"},"spec":{"replicas":0}}`, patched: true, replicas: 0, phase: redisv1.PhasePending},
+		{name: "nothing to do", object: `{"metadata":{"name":"test"},"spec":{"replicas":3,"phase":"Running"}}`, replicas: 3, phase: redisv1.PhaseRunning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := review(t, server, MutatePath, redisKind, tt.object)
+			if !resp.Allowed {
+				t.Fatalf("denied: %+v", resp.Result)
+			}
+			if !tt.patched {
+				if resp.Patch != nil || resp.PatchType != nil {
+					t.Errorf("unexpected patch %s", resp.Patch)
+				}
+				return
+			}
+			if resp.PatchType == nil || *resp.PatchType != admissionv1.PatchTypeJSONPatch {
+				t.Fatalf("patchType = %v, want JSONPatch", resp.PatchType)
+			}
+
+			// 把补丁应用到原对象上检查结果
+			patch, err := jsonpatch.DecodePatch(resp.Patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			patched, err := patch.Apply([]byte(tt.object))
+			if err != nil {
+				t.Fatal(err)
+			}
+			redis := &redisv1.Redis{}
+			if err := json.Unmarshal(patched, redis); err != nil {
+				t.Fatal(err)
+			}
+			if redis.Spec.Replicas == nil || *redis.Spec.Replicas != tt.replicas || redis.Spec.Phase != tt.phase {
+				t.Errorf("patched spec = %s", patched)
+			}
+		})
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	server := httptest.NewServer(NewHandler())
+	defer server.Close()
+
+	resp := review(t, server, ValidatePath, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, `{}`)
+	if resp.Allowed || resp.Result.Reason != metav1.StatusReasonBadRequest {
+		t.Errorf("wrong kind: %+v", resp)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		code        int
+	}{
+		{name: "get", method: http.MethodGet, contentType: "application/json", code: http.StatusMethodNotAllowed},
+		{name: "yaml", method: http.MethodPost, contentType: "application/yaml", body: "{}", code: http.StatusUnsupportedMediaType},
+		{name: "text", method: http.MethodPost, contentType: "text/plain; charset=utf-8", body: "{}", code: http.StatusUnsupportedMediaType},
+		{name: "invalid json", method: http.MethodPost, contentType: "application/json", body: "{", code: http.StatusBadRequest},
+		{name: "charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: "{}", code: http.StatusBadRequest},
+		{name: "no request", method: http.MethodPost, contentType: "application/json", body: "{}", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+ValidatePath, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.code {
+				t.Errorf("status code = %d, want %d", resp.StatusCode, tt.code)
+			}
+		})
+	}
+}